@@ -2,9 +2,14 @@ package promaggr
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
 	"sync"
+	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/prometheus/client_golang/prometheus"
@@ -13,12 +18,21 @@ import (
 	"github.com/prometheus/common/model"
 )
 
+// acceptHeader is the Accept header sent by the Scraper.
+// The protobuf format is preferred, and the text format is used as a fallback.
+const acceptHeader = `application/vnd.google.protobuf;proto=io.prometheus.client.MetricFamily;encoding=delimited;q=0.7,text/plain;version=0.0.4;q=0.3,*/*;q=0.1`
+
+// ErrUnexpectedStatusCode is returned when the scraping target responds with a status code other than 200.
+var ErrUnexpectedStatusCode = errors.New("unexpected status code")
+
 // ScraperOption is a functional option used by the NewScraper.
 type ScraperOption func(*Scraper)
 
 // Scraper will scrape metrics from prometheus exporter.
 type Scraper struct {
 	// URL is the URL of the scraping target.
+	// A target listening on a Unix domain socket can be specified in the form of "unix:///path/to.sock:/metrics",
+	// which cannot be combined with the HTTPClient.
 	URL string
 
 	// Labels is a set of labels to be added to the scraped metrics.
@@ -27,6 +41,98 @@ type Scraper struct {
 
 	// HTTPClient is the http.Client to be used for the request. If not specified, the http.DefaultClient will be used.
 	HTTPClient *http.Client
+
+	// Match is a set of series selectors sent as match[] parameters.
+	// It is used to federate from the /federate endpoint of the Prometheus server.
+	// If not specified, no match[] parameters will be sent.
+	Match []string
+
+	// HonorLabels controls how conflicts between the scraped labels and Labels are resolved.
+	// If true, the scraped labels are kept and the conflicting Labels are ignored.
+	// If false, the conflicting scraped labels are overridden by Labels.
+	HonorLabels bool
+
+	// ExportConflictingLabels keeps the scraped labels overridden by Labels as "exported_<label name>",
+	// in the same way as Prometheus with honor_labels set to false.
+	// It has no effect if HonorLabels is true.
+	ExportConflictingLabels bool
+
+	// DropTimestamps controls whether the timestamps exposed by the target are dropped.
+	// If false, the timestamps are kept, in the same way as Prometheus with honor_timestamps set to true.
+	DropTimestamps bool
+
+	// SampleLimit is the maximum number of samples per scrape. If exceeded, the scrape fails.
+	// If 0, there is no limit.
+	SampleLimit int
+
+	// LabelLimit is the maximum number of labels per metric, including the Labels. If exceeded, the scrape fails.
+	// If 0, there is no limit.
+	LabelLimit int
+
+	// LabelNameLengthLimit is the maximum length of a label name. If exceeded, the scrape fails.
+	// If 0, there is no limit.
+	LabelNameLengthLimit int
+
+	// LabelValueLengthLimit is the maximum length of a label value. If exceeded, the scrape fails.
+	// If 0, there is no limit.
+	LabelValueLengthLimit int
+
+	// BodySizeLimit is the maximum size of the decompressed response body in bytes. If exceeded, the scrape fails.
+	// The limit is enforced while the response body is read.
+	// If 0, there is no limit.
+	BodySizeLimit int64
+
+	// Metrics is the ScrapeMetrics to record the scrape results to.
+	// If not specified, nothing will be recorded.
+	Metrics *ScrapeMetrics
+
+	// BasicAuthUsername is the username for the basic authentication.
+	BasicAuthUsername string
+
+	// BasicAuthPassword is the password for the basic authentication.
+	BasicAuthPassword string
+
+	// BasicAuthPasswordFile is the file to read the password for the basic authentication from.
+	// It takes precedence over BasicAuthPassword.
+	BasicAuthPasswordFile string
+
+	// BearerToken is the bearer token to be sent in the Authorization header.
+	BearerToken string
+
+	// BearerTokenFile is the file to read the bearer token from.
+	// It takes precedence over BearerToken.
+	BearerTokenFile string
+
+	// TLSConfig configures the TLS connection to the scraping target.
+	// It is ignored if HTTPClient is specified.
+	TLSConfig *TLSConfig
+
+	// Timeout is the time limit for a scrape. If exceeded, the scrape fails.
+	// If 0, there is no limit other than the context.
+	Timeout time.Duration
+
+	// Method is the HTTP method of the request. If not specified, GET will be used.
+	Method string
+
+	// Headers is a set of headers to be added to the request.
+	Headers http.Header
+
+	// Params is a set of query parameters to be added to the request.
+	Params url.Values
+
+	// UnixSocket is the path of the Unix domain socket to send the request over.
+	// If specified, the host of the URL will be ignored.
+	// It is ignored if HTTPClient is specified.
+	UnixSocket string
+
+	// ProxyURL is the URL of the proxy server to send the request through.
+	// If not specified, the proxy is determined by the environment variables.
+	// It is ignored if HTTPClient is specified.
+	ProxyURL *url.URL
+
+	secrets    secretFiles
+	clientOnce sync.Once
+	client     *http.Client
 }
 
 // NewScraper creates and returns a new Scraper.
@@ -58,62 +164,389 @@ func HTTPClient(client *http.Client) ScraperOption {
 	}
 }
 
-// Scrape.
+// Federate is an option available for NewScraper.
+// The given series selectors will be sent as match[] parameters
+// to federate from the /federate endpoint of the Prometheus server.
+func Federate(matchers ...string) ScraperOption {
+	return func(s *Scraper) {
+		s.Match = matchers
+	}
+}
+
+// Timeout is an option available for NewScraper.
+// The scrape fails if it does not finish within the timeout.
+func Timeout(timeout time.Duration) ScraperOption {
+	return func(s *Scraper) {
+		s.Timeout = timeout
+	}
+}
+
+// Method is an option available for NewScraper.
+// The request will be sent with the given HTTP method.
+func Method(method string) ScraperOption {
+	return func(s *Scraper) {
+		s.Method = method
+	}
+}
+
+// Headers is an option available for NewScraper.
+// The given headers will be added to the request.
+// The headers set by the Scraper, such as Accept, can be overridden.
+func Headers(headers http.Header) ScraperOption {
+	return func(s *Scraper) {
+		s.Headers = headers
+	}
+}
+
+// Params is an option available for NewScraper.
+// The given query parameters will be added to the request.
+func Params(params url.Values) ScraperOption {
+	return func(s *Scraper) {
+		s.Params = params
+	}
+}
+
+// ProxyURL is an option available for NewScraper.
+// The request will be sent through the proxy server of the given URL.
+func ProxyURL(proxyURL *url.URL) ScraperOption {
+	return func(s *Scraper) {
+		s.ProxyURL = proxyURL
+	}
+}
+
+// HonorLabels is an option available for NewScraper.
+// If true, the scraped labels take precedence over the labels set by the Labels option.
+func HonorLabels(honor bool) ScraperOption {
+	return func(s *Scraper) {
+		s.HonorLabels = honor
+	}
+}
+
+// ExportConflictingLabels is an option available for NewScraper.
+// If true, the scraped labels overridden by the labels set by the Labels option are kept as "exported_<label name>".
+func ExportConflictingLabels(export bool) ScraperOption {
+	return func(s *Scraper) {
+		s.ExportConflictingLabels = export
+	}
+}
+
+// HonorTimestamps is an option available for NewScraper.
+// If false, the timestamps exposed by the target will be dropped. They are kept by default.
+func HonorTimestamps(honor bool) ScraperOption {
+	return func(s *Scraper) {
+		s.DropTimestamps = !honor
+	}
+}
+
+// SampleLimit is an option available for NewScraper.
+// The scrape fails if the number of samples exceeds the limit.
+func SampleLimit(limit int) ScraperOption {
+	return func(s *Scraper) {
+		s.SampleLimit = limit
+	}
+}
+
+// LabelLimit is an option available for NewScraper.
+// The scrape fails if the number of labels of any metric exceeds the limit.
+func LabelLimit(limit int) ScraperOption {
+	return func(s *Scraper) {
+		s.LabelLimit = limit
+	}
+}
+
+// LabelNameLengthLimit is an option available for NewScraper.
+// The scrape fails if the length of any label name exceeds the limit.
+func LabelNameLengthLimit(limit int) ScraperOption {
+	return func(s *Scraper) {
+		s.LabelNameLengthLimit = limit
+	}
+}
+
+// LabelValueLengthLimit is an option available for NewScraper.
+// The scrape fails if the length of any label value exceeds the limit.
+func LabelValueLengthLimit(limit int) ScraperOption {
+	return func(s *Scraper) {
+		s.LabelValueLengthLimit = limit
+	}
+}
+
+// BodySizeLimit is an option available for NewScraper.
+// The scrape fails if the size of the response body exceeds the limit in bytes.
+func BodySizeLimit(limit int64) ScraperOption {
+	return func(s *Scraper) {
+		s.BodySizeLimit = limit
+	}
+}
+
+// Metrics is an option available for NewScraper.
+// The scrape results will be recorded to the given ScrapeMetrics.
+func Metrics(metrics *ScrapeMetrics) ScraperOption {
+	return func(s *Scraper) {
+		s.Metrics = metrics
+	}
+}
+
+// ID returns the identifier of the target, which consists of the URL and the Labels.
+// It is used to add or remove the Scraper from a Collector.
+func (s *Scraper) ID() string {
+	if len(s.Labels) == 0 {
+		return s.URL
+	}
+
+	return s.URL + s.Labels.String()
+}
+
+// Fetch implements the Source interface.
+// It is the same as Scrape.
+func (s *Scraper) Fetch(ctx context.Context) ([]*dto.MetricFamily, error) {
+	return s.Scrape(ctx)
+}
+
+// Scrape scrapes metrics from the target and returns them.
+// The protobuf format is negotiated, and the text format is used as a fallback.
+// The response body is requested to be compressed with zstd or gzip, and decompressed while it is read.
+// If the scraped metrics exceed any of the limits, a LimitError is returned,
+// and if the response body exceeds the BodySizeLimit, ErrBodySizeLimitExceeded is returned.
 func (s *Scraper) Scrape(ctx context.Context) ([]*dto.MetricFamily, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL, nil)
+	if s.Timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, s.Timeout)
+		defer cancel()
+	}
+
+	req, err := s.newRequest(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, err
 	}
 
-	var client *http.Client
+	resp, err := s.httpClient().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to request to %s: %w", s.URL, err)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to request to %s: %w: %d", s.URL, ErrUnexpectedStatusCode, resp.StatusCode)
+	}
+
+	compressed := &countingReader{r: resp.Body}
+
+	decompressed, err := decompress(compressed, resp.Header.Get("Content-Encoding"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to scrape %s: %w", s.URL, err)
+	}
+
+	defer decompressed.Close()
+
+	uncompressed := &countingReader{r: decompressed}
+
+	defer func() {
+		s.Metrics.addBytes(s.URL, compressed.n, uncompressed.n)
+	}()
+
+	body := io.Reader(uncompressed)
+	if s.BodySizeLimit > 0 {
+		body = &sizeLimitedReader{r: body, remaining: s.BodySizeLimit}
+	}
+
+	mfs, err := s.decode(NewDecoder(body, expfmt.ResponseFormat(resp.Header)))
+	if err != nil {
+		var limitErr *LimitError
+
+		switch {
+		case errors.As(err, &limitErr):
+			s.Metrics.incExceededLimit(s.URL, limitErr.limitName())
+		case errors.Is(err, ErrBodySizeLimitExceeded):
+			s.Metrics.incExceededLimit(s.URL, "body_size_limit")
+		}
+
+		return nil, fmt.Errorf("failed to scrape %s: %w", s.URL, err)
+	}
+
+	return mfs, nil
+}
+
+// decode decodes the scraped MetricFamily's one by one.
+// The timestamps and labels are processed and the limits are checked as each MetricFamily is decoded,
+// so that the scrape fails as soon as any of the limits is exceeded.
+func (s *Scraper) decode(decoder expfmt.Decoder) ([]*dto.MetricFamily, error) {
+	limiter := &limiter{
+		sampleLimit:           s.SampleLimit,
+		labelLimit:            s.LabelLimit,
+		labelNameLengthLimit:  s.LabelNameLengthLimit,
+		labelValueLengthLimit: s.LabelValueLengthLimit,
+	}
+
+	return decodeMetricFamilies(decoder, func(mf *dto.MetricFamily) error {
+		if s.DropTimestamps {
+			dropTimestamps(mf)
+		}
+
+		if s.Labels != nil {
+			addTargetLabels([]*dto.MetricFamily{mf}, s.Labels, s.HonorLabels, s.ExportConflictingLabels)
+		}
+
+		return limiter.check(mf)
+	})
+}
 
+// httpClient returns the http.Client to be used for the request.
+// If the HTTPClient is not specified, the http.Client is built from the configuration of the Scraper.
+func (s *Scraper) httpClient() *http.Client {
 	if s.HTTPClient != nil {
-		client = s.HTTPClient
+		return s.HTTPClient
+	}
+
+	if s.TLSConfig == nil && s.ProxyURL == nil && s.unixSocket() == "" {
+		return http.DefaultClient
+	}
+
+	s.clientOnce.Do(func() {
+		var transport http.RoundTripper
+
+		if s.TLSConfig != nil {
+			transport = newTLSRoundTripper(*s.TLSConfig, s.newTransport)
+		} else {
+			transport = s.newTransport(nil)
+		}
+
+		s.client = &http.Client{
+			Transport: transport,
+		}
+	})
+
+	return s.client
+}
+
+// newTransport returns a new http.Transport based on the http.DefaultTransport using the given tls.Config.
+func (s *Scraper) newTransport(config *tls.Config) *http.Transport {
+	var transport *http.Transport
+
+	if t, ok := http.DefaultTransport.(*http.Transport); ok {
+		transport = t.Clone()
 	} else {
-		client = http.DefaultClient
+		transport = &http.Transport{}
 	}
 
-	resp, err := client.Do(req)
+	transport.TLSClientConfig = config
+
+	if s.ProxyURL != nil {
+		transport.Proxy = http.ProxyURL(s.ProxyURL)
+	}
+
+	if socket := s.unixSocket(); socket != "" {
+		transport.DialContext = dialUnix(socket)
+		transport.Proxy = nil
+	}
+
+	return transport
+}
+
+// newRequest creates a new request to the scraping target.
+func (s *Scraper) newRequest(ctx context.Context) (*http.Request, error) {
+	rawURL := s.URL
+	if _, httpURL, ok := parseUnixURL(rawURL); ok {
+		if s.HTTPClient != nil {
+			return nil, fmt.Errorf("%w: %s", ErrUnixURLWithHTTPClient, s.URL)
+		}
+
+		rawURL = httpURL
+	}
+
+	u, err := url.Parse(rawURL)
 	if err != nil {
-		return nil, fmt.Errorf("failed to request to %s: %w", s.URL, err)
+		return nil, fmt.Errorf("failed to parse URL: %w", err)
 	}
 
-	defer resp.Body.Close()
+	if len(s.Match) > 0 || len(s.Params) > 0 {
+		query := u.Query()
+
+		for name, values := range s.Params {
+			for _, v := range values {
+				query.Add(name, v)
+			}
+		}
 
-	var parser expfmt.TextParser
+		for _, m := range s.Match {
+			query.Add("match[]", m)
+		}
+
+		u.RawQuery = query.Encode()
+	}
 
-	parsed, err := parser.TextToMetricFamilies(resp.Body)
+	method := s.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse metric: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	mfs := make([]*dto.MetricFamily, 0, len(parsed))
+	req.Header.Set("Accept", acceptHeader)
+	req.Header.Set("Accept-Encoding", acceptEncodingHeader)
+
+	for name, values := range s.Headers {
+		// The Host header is ignored by the http.Client, so it has to be set to the request.
+		if http.CanonicalHeaderKey(name) == "Host" && len(values) > 0 {
+			req.Host = values[0]
+
+			continue
+		}
+
+		req.Header.Del(name)
 
-	for _, mf := range parsed {
-		mfs = append(mfs, mf)
+		for _, v := range values {
+			req.Header.Add(name, v)
+		}
 	}
 
-	if s.Labels != nil {
-		AddLabels(mfs, s.Labels)
+	if err := s.authorize(req); err != nil {
+		return nil, fmt.Errorf("failed to authorize request to %s: %w", s.URL, err)
 	}
 
-	return mfs, nil
+	return req, nil
+}
+
+// dropTimestamps removes the timestamps from all metrics in the given MetricFamily.
+func dropTimestamps(mf *dto.MetricFamily) {
+	for _, m := range mf.GetMetric() {
+		m.TimestampMs = nil
+	}
 }
 
 var _ prometheus.Collector = &Collector{}
 
+// ErrScraperExists is returned when a Scraper with the same ID is already added to the Collector.
+var ErrScraperExists = errors.New("scraper already exists")
+
 // Collector implements the prometheus.Collector interface.
 type Collector struct {
+	// Scrapers is a list of Scrapers to scrape metrics from.
 	//
+	// Deprecated: Modifying Scrapers while the Collector is collecting metrics is not safe.
+	// Use AddScraper, RemoveScraper and ReplaceScrapers instead, and ScraperList to read them.
 	Scrapers []*Scraper
 
 	// Logger is a logger that implements the logr.Logger interface.
 	// If it is not specified, nothing will be logged.
 	Logger logr.Logger
 
+	// MergeOptions is a list of options used to merge the metrics of the sources.
+	MergeOptions []MergeAllOption
+
+	sources []Source
+
 	once  sync.Once
 	mutex sync.RWMutex
-	cache []*dto.MetricFamily
+	// results holds the last fetched metrics by source identifier.
+	results map[string][]*dto.MetricFamily
+	// statuses holds the status of the last fetch by source identifier.
+	statuses map[string]TargetStatus
+	cache    []*dto.MetricFamily
 }
 
 // CollectorOption is a functional option used by the NewCollector.
@@ -142,6 +575,137 @@ func Logger(logger logr.Logger) CollectorOption {
 	}
 }
 
+// Sources is an option available for NewCollector.
+// The metrics fetched from the given sources will be merged with the scraped metrics.
+func Sources(sources ...Source) CollectorOption {
+	return func(c *Collector) {
+		c.sources = append(c.sources, sources...)
+	}
+}
+
+// MergeOptions is an option available for NewCollector.
+// The metrics of the sources are merged with the given options, e.g. IdentifierLabel.
+func MergeOptions(opts ...MergeAllOption) CollectorOption {
+	return func(c *Collector) {
+		c.MergeOptions = append(c.MergeOptions, opts...)
+	}
+}
+
+// FileSources is an option available for NewCollector.
+// The metrics read by the FileSource's will be merged with the scraped metrics.
+func FileSources(sources ...*FileSource) CollectorOption {
+	return func(c *Collector) {
+		for _, source := range sources {
+			c.sources = append(c.sources, source)
+		}
+	}
+}
+
+// ScraperList returns a snapshot of the Scrapers of the Collector.
+func (c *Collector) ScraperList() []*Scraper {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return append([]*Scraper(nil), c.Scrapers...)
+}
+
+// AddScraper adds the Scraper to the Collector.
+// If a Scraper with the same ID is already added, ErrScraperExists is returned.
+// It is safe to call while the Collector is collecting metrics.
+func (c *Collector) AddScraper(scraper *Scraper) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	id := scraper.ID()
+
+	for _, s := range c.Scrapers {
+		if s.ID() == id {
+			return fmt.Errorf("%w: %s", ErrScraperExists, id)
+		}
+	}
+
+	// The Scrapers are copied so that the slice given to NewCollector is not modified.
+	c.Scrapers = append(c.Scrapers[:len(c.Scrapers):len(c.Scrapers)], scraper)
+
+	return nil
+}
+
+// RemoveScraper removes the Scrapers with the given ID from the Collector,
+// along with their cached metrics.
+// It reports whether any Scraper was removed.
+// It is safe to call while the Collector is collecting metrics.
+func (c *Collector) RemoveScraper(id string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	scrapers := make([]*Scraper, 0, len(c.Scrapers))
+
+	for _, s := range c.Scrapers {
+		if s.ID() != id {
+			scrapers = append(scrapers, s)
+		}
+	}
+
+	if len(scrapers) == len(c.Scrapers) {
+		return false
+	}
+
+	c.Scrapers = scrapers
+	c.pruneLocked()
+
+	return true
+}
+
+// ReplaceScrapers replaces all Scrapers of the Collector with the given Scrapers at once.
+// The cached metrics of the Scrapers that are no longer present are removed.
+// It is safe to call while the Collector is collecting metrics.
+func (c *Collector) ReplaceScrapers(scrapers []*Scraper) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.Scrapers = append([]*Scraper(nil), scrapers...)
+	c.pruneLocked()
+}
+
+// sourcesLocked returns the Scrapers and the other sources of the Collector.
+// The caller must hold the mutex.
+func (c *Collector) sourcesLocked() []Source {
+	sources := make([]Source, 0, len(c.Scrapers)+len(c.sources))
+	for _, scraper := range c.Scrapers {
+		sources = append(sources, scraper)
+	}
+
+	return append(sources, c.sources...)
+}
+
+// pruneLocked removes the results and the statuses of the sources that are no longer present,
+// and merges the rest of the results into the cache.
+// The caller must hold the write lock of the mutex.
+func (c *Collector) pruneLocked() []Conflict {
+	current := make(map[string]struct{}, len(c.results))
+	for _, identifier := range sourceIdentifiers(c.sourcesLocked()) {
+		current[identifier] = struct{}{}
+	}
+
+	for identifier := range c.results {
+		if _, ok := current[identifier]; !ok {
+			delete(c.results, identifier)
+		}
+	}
+
+	for identifier := range c.statuses {
+		if _, ok := current[identifier]; !ok {
+			delete(c.statuses, identifier)
+		}
+	}
+
+	var conflicts []Conflict
+
+	c.cache, conflicts = MergeAll(c.results, c.MergeOptions...)
+
+	return conflicts
+}
+
 // Describe implements the prometheus.Collector interface.
 // Register prometheus.Desc.
 // It is called at registration time and is used to avoid duplicate registration of metrics.
@@ -174,59 +738,39 @@ func (c *Collector) Collect(ch chan<- prometheus.Metric) {
 }
 
 // rsyncCache will update the scrape results of the metrics kept by the Collector.
-// Use goroutine to scrape from multiple prometheus exporter and merge the results.
+// Use goroutine to fetch from multiple prometheus exporter and other sources, and merge the results.
 func (c *Collector) rsyncCache(ctx context.Context) {
-	var wg sync.WaitGroup
-
-	mfsCh := make(chan []*dto.MetricFamily)
-	errCh := make(chan error)
-	done := make(chan struct{})
-
-	newMfs := make([]*dto.MetricFamily, 0)
+	c.mutex.RLock()
+	sources := c.sourcesLocked()
+	c.mutex.RUnlock()
 
-	go func() {
-		for mfs := range mfsCh {
-			newMfs = MergeMetricFamily(newMfs, mfs)
-		}
+	inputs := make(map[string][]*dto.MetricFamily, len(sources))
+	statuses := make(map[string]TargetStatus, len(sources))
 
-		close(done)
-	}()
+	for _, result := range fetchAll(ctx, sources) {
+		statuses[result.identifier] = newTargetStatus(result)
 
-	go func() {
-		for err := range errCh {
+		if result.err != nil {
 			if c.Logger != nil {
-				c.Logger.Error(err, "failed to scrape prometheus exporter")
+				c.Logger.Error(result.err, "failed to fetch metrics from source", "source", result.identifier)
 			}
-		}
-	}()
-
-	for _, scraper := range c.Scrapers {
-		scraper := scraper
-
-		wg.Add(1)
-
-		go func() {
-			defer wg.Done()
-
-			mfs, err := scraper.Scrape(ctx)
-			if err != nil {
-				errCh <- err
 
-				return
-			}
+			continue
+		}
 
-			mfsCh <- mfs
-		}()
+		inputs[result.identifier] = result.mfs
 	}
 
-	wg.Wait()
-	close(mfsCh)
-	close(errCh)
-
-	<-done
-
+	// The sources may have been removed while fetching, so only the results of the current ones are kept.
 	c.mutex.Lock()
-	defer c.mutex.Unlock()
-
-	c.cache = newMfs
+	c.results = inputs
+	c.statuses = statuses
+	conflicts := c.pruneLocked()
+	c.mutex.Unlock()
+
+	if c.Logger != nil {
+		for _, conflict := range conflicts {
+			c.Logger.Error(conflict, "dropped conflicting metrics while merging")
+		}
+	}
 }
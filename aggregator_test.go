@@ -1,16 +1,25 @@
 package promaggr_test
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/d-kuro/promaggr"
+	"github.com/d-kuro/promaggr/internal"
 	"github.com/google/go-cmp/cmp"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
 	"github.com/prometheus/common/model"
 )
 
@@ -77,6 +86,486 @@ http_requests_total{cluster="foo",code="200",method="GET"} 1
 	}
 }
 
+func TestCollectorSources(t *testing.T) {
+	t.Parallel()
+
+	scrapeTargetCounter := newHTTPRequestCounter()
+	scrapeTargetRegistry := prometheus.NewRegistry()
+	scrapeTargetRegistry.MustRegister(scrapeTargetCounter)
+	scrapeTargetCounter.WithLabelValues("200", http.MethodGet).Inc()
+
+	scrapeTarget := httptest.NewServer(promhttp.HandlerFor(scrapeTargetRegistry, promhttp.HandlerOpts{}))
+	defer scrapeTarget.Close()
+
+	scrapers := []*promaggr.Scraper{
+		promaggr.NewScraper(scrapeTarget.URL, promaggr.Labels(map[model.LabelName]model.LabelValue{"cluster": "foo"})),
+	}
+
+	source := promaggr.SourceFunc(func(ctx context.Context) ([]*dto.MetricFamily, error) {
+		return []*dto.MetricFamily{
+			internal.NewCounterMetricFamilyFixture("http_requests_total",
+				internal.Label([]*dto.LabelPair{
+					{Name: internal.StringToPointer("cluster"), Value: internal.StringToPointer("bar")},
+					{Name: internal.StringToPointer("code"), Value: internal.StringToPointer("200")},
+					{Name: internal.StringToPointer("method"), Value: internal.StringToPointer("GET")},
+				}),
+			),
+		}, nil
+	})
+
+	collector := promaggr.NewCollector(scrapers, promaggr.Sources(source))
+	aggregatorRegistry := prometheus.NewRegistry()
+	aggregatorRegistry.MustRegister(collector)
+
+	mfs, err := aggregatorRegistry.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+
+	out := bytes.Buffer{}
+
+	for _, mf := range mfs {
+		if _, err := expfmt.MetricFamilyToText(&out, mf); err != nil {
+			t.Fatalf("failed to convert MetricFamily to text: %v", err)
+		}
+	}
+
+	got := out.String()
+	want := `# HELP http_requests_total Dummy text.
+# TYPE http_requests_total counter
+http_requests_total{cluster="bar",code="200",method="GET"} 123456
+http_requests_total{cluster="foo",code="200",method="GET"} 1
+`
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("prometheus metrics mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestCollectorMergeOptions(t *testing.T) {
+	t.Parallel()
+
+	scrapeTargetCounter := newHTTPRequestCounter()
+	scrapeTargetRegistry := prometheus.NewRegistry()
+	scrapeTargetRegistry.MustRegister(scrapeTargetCounter)
+	scrapeTargetCounter.WithLabelValues("200", http.MethodGet).Inc()
+
+	scrapeTarget := httptest.NewServer(promhttp.HandlerFor(scrapeTargetRegistry, promhttp.HandlerOpts{}))
+	defer scrapeTarget.Close()
+
+	collector := promaggr.NewCollector(
+		[]*promaggr.Scraper{promaggr.NewScraper(scrapeTarget.URL)},
+		promaggr.MergeOptions(promaggr.IdentifierLabel("source")),
+	)
+	aggregatorRegistry := prometheus.NewRegistry()
+	aggregatorRegistry.MustRegister(collector)
+
+	mfs, err := aggregatorRegistry.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+
+	out := bytes.Buffer{}
+
+	for _, mf := range mfs {
+		if _, err := expfmt.MetricFamilyToText(&out, mf); err != nil {
+			t.Fatalf("failed to convert MetricFamily to text: %v", err)
+		}
+	}
+
+	got := out.String()
+	want := `# HELP http_requests_total Dummy text.
+# TYPE http_requests_total counter
+http_requests_total{code="200",method="GET",source="` + scrapeTarget.URL + `"} 1
+`
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("prometheus metrics mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestCollectorScrapers(t *testing.T) {
+	t.Parallel()
+
+	scrapeTargetCounter := newHTTPRequestCounter()
+	scrapeTargetRegistry := prometheus.NewRegistry()
+	scrapeTargetRegistry.MustRegister(scrapeTargetCounter)
+	scrapeTargetCounter.WithLabelValues("200", http.MethodGet).Inc()
+
+	scrapeTarget := httptest.NewServer(promhttp.HandlerFor(scrapeTargetRegistry, promhttp.HandlerOpts{}))
+	defer scrapeTarget.Close()
+
+	foo := promaggr.NewScraper(scrapeTarget.URL, promaggr.Labels(model.LabelSet{"cluster": "foo"}))
+	bar := promaggr.NewScraper(scrapeTarget.URL, promaggr.Labels(model.LabelSet{"cluster": "bar"}))
+	baz := promaggr.NewScraper(scrapeTarget.URL, promaggr.Labels(model.LabelSet{"cluster": "baz"}))
+
+	collector := promaggr.NewCollector([]*promaggr.Scraper{foo})
+	collector.Describe(make(chan *prometheus.Desc, 10))
+
+	if err := collector.AddScraper(bar); err != nil {
+		t.Fatalf("failed to add scraper: %v", err)
+	}
+
+	if err := collector.AddScraper(promaggr.NewScraper(scrapeTarget.URL, promaggr.Labels(model.LabelSet{"cluster": "bar"}))); !errors.Is(err, promaggr.ErrScraperExists) {
+		t.Errorf("error mismatch: want(%v) got(%v)", promaggr.ErrScraperExists, err)
+	}
+
+	gatherClusters := func() []string {
+		t.Helper()
+
+		mfs, err := collector.Gather()
+		if err != nil {
+			t.Fatalf("failed to gather metrics: %v", err)
+		}
+
+		var clusters []string
+
+		for _, mf := range mfs {
+			for _, m := range mf.GetMetric() {
+				for _, lp := range m.GetLabel() {
+					if lp.GetName() == "cluster" {
+						clusters = append(clusters, lp.GetValue())
+					}
+				}
+			}
+		}
+
+		return clusters
+	}
+
+	if diff := cmp.Diff([]string{"bar", "foo"}, gatherClusters()); diff != "" {
+		t.Errorf("clusters mismatch (-want +got):\n%s", diff)
+	}
+
+	if !collector.RemoveScraper(foo.ID()) {
+		t.Errorf("scraper %s was not removed", foo.ID())
+	}
+
+	if collector.RemoveScraper(foo.ID()) {
+		t.Errorf("scraper %s was removed twice", foo.ID())
+	}
+
+	collector.ReplaceScrapers([]*promaggr.Scraper{bar, baz})
+
+	if diff := cmp.Diff([]string{"bar", "baz"}, gatherClusters()); diff != "" {
+		t.Errorf("clusters mismatch (-want +got):\n%s", diff)
+	}
+
+	want := []string{bar.ID(), baz.ID()}
+
+	var got []string
+	for _, scraper := range collector.ScraperList() {
+		got = append(got, scraper.ID())
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("scraper IDs mismatch (-want +got):\n%s", diff)
+	}
+
+	// The cached metrics of the removed scrapers must not be described.
+	collector.ReplaceScrapers(nil)
+
+	descs := make(chan *prometheus.Desc, 10)
+	collector.Describe(descs)
+	close(descs)
+
+	for desc := range descs {
+		t.Errorf("desc of a removed scraper is described: %s", desc)
+	}
+}
+
+func TestCollectorScrapersConcurrently(t *testing.T) {
+	t.Parallel()
+
+	scrapeTarget := httptest.NewServer(promhttp.HandlerFor(prometheus.NewRegistry(), promhttp.HandlerOpts{}))
+	defer scrapeTarget.Close()
+
+	collector := promaggr.NewCollector(nil)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 10; i++ {
+		i := i
+
+		wg.Add(2)
+
+		go func() {
+			defer wg.Done()
+
+			scraper := promaggr.NewScraper(scrapeTarget.URL, promaggr.Labels(model.LabelSet{"n": model.LabelValue(strconv.Itoa(i))}))
+
+			if err := collector.AddScraper(scraper); err != nil {
+				t.Errorf("failed to add scraper: %v", err)
+			}
+
+			collector.RemoveScraper(scraper.ID())
+		}()
+
+		go func() {
+			defer wg.Done()
+
+			if _, err := collector.Gather(); err != nil {
+				t.Errorf("failed to gather metrics: %v", err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if got := len(collector.ScraperList()); got != 0 {
+		t.Errorf("number of scrapers mismatch: want(0) got(%d)", got)
+	}
+}
+
+func TestCollectorFederateDifferentLabels(t *testing.T) {
+	t.Parallel()
+
+	const federateText = `# TYPE up untyped
+up{instance="x",job="a"} 1
+up{cluster="z",instance="y",job="b"} 1
+`
+
+	federateTarget := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", string(expfmt.FmtText))
+		_, _ = io.WriteString(w, federateText)
+	}))
+	defer federateTarget.Close()
+
+	scraper := promaggr.NewScraper(federateTarget.URL+"/federate",
+		promaggr.Federate(`up`),
+		promaggr.Labels(model.LabelSet{"cluster": "foo"}),
+		promaggr.ExportConflictingLabels(true),
+	)
+
+	// The metrics of a family with different label names are collected without panicking.
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(promaggr.NewCollector([]*promaggr.Scraper{scraper}))
+
+	mfs, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+
+	out := bytes.Buffer{}
+
+	for _, mf := range mfs {
+		if _, err := expfmt.MetricFamilyToText(&out, mf); err != nil {
+			t.Fatalf("failed to convert MetricFamily to text: %v", err)
+		}
+	}
+
+	want := `# HELP up 
+# TYPE up untyped
+up{cluster="foo",instance="x",job="a"} 1
+up{cluster="foo",exported_cluster="z",instance="y",job="b"} 1
+`
+
+	if diff := cmp.Diff(want, out.String()); diff != "" {
+		t.Errorf("prometheus metrics mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestScraperFederate(t *testing.T) {
+	t.Parallel()
+
+	const federateText = `# TYPE up untyped
+up{cluster="baz",instance="localhost:9090",job="prometheus"} 1 1625097600000
+`
+
+	federateTarget := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if diff := cmp.Diff([]string{`{job="prometheus"}`, `up`}, r.URL.Query()["match[]"]); diff != "" {
+			t.Errorf("match[] parameters mismatch (-want +got):\n%s", diff)
+		}
+
+		w.Header().Set("Content-Type", string(expfmt.FmtText))
+		_, _ = io.WriteString(w, federateText)
+	}))
+	t.Cleanup(federateTarget.Close)
+
+	tests := []struct {
+		name string
+		opts []promaggr.ScraperOption
+		want string
+	}{
+		{
+			name: "default",
+			opts: nil,
+			want: `# TYPE up untyped
+up{cluster="foo",instance="localhost:9090",job="prometheus"} 1 1625097600000
+`,
+		},
+		{
+			name: "honor labels",
+			opts: []promaggr.ScraperOption{promaggr.HonorLabels(true), promaggr.HonorTimestamps(true)},
+			want: `# TYPE up untyped
+up{cluster="baz",instance="localhost:9090",job="prometheus"} 1 1625097600000
+`,
+		},
+		{
+			name: "do not honor labels",
+			opts: []promaggr.ScraperOption{
+				promaggr.HonorLabels(false),
+				promaggr.ExportConflictingLabels(true),
+				promaggr.HonorTimestamps(false),
+			},
+			want: `# TYPE up untyped
+up{cluster="foo",exported_cluster="baz",instance="localhost:9090",job="prometheus"} 1
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			opts := append([]promaggr.ScraperOption{
+				promaggr.Federate(`{job="prometheus"}`, `up`),
+				promaggr.Labels(map[model.LabelName]model.LabelValue{"cluster": "foo"}),
+			}, tt.opts...)
+
+			scraper := promaggr.NewScraper(federateTarget.URL+"/federate", opts...)
+
+			mfs, err := scraper.Scrape(context.Background())
+			if err != nil {
+				t.Fatalf("failed to scrape: %v", err)
+			}
+
+			out := bytes.Buffer{}
+
+			for _, mf := range mfs {
+				if _, err := expfmt.MetricFamilyToText(&out, mf); err != nil {
+					t.Fatalf("failed to convert MetricFamily to text: %v", err)
+				}
+			}
+
+			if diff := cmp.Diff(tt.want, out.String()); diff != "" {
+				t.Errorf("federated metrics mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestScraperBodySizeLimit(t *testing.T) {
+	t.Parallel()
+
+	scrapeTarget := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", string(expfmt.FmtText))
+		_, _ = io.WriteString(w, metricsText)
+	}))
+	t.Cleanup(scrapeTarget.Close)
+
+	tests := []struct {
+		name    string
+		limit   int64
+		wantErr error
+	}{
+		{
+			name:    "exceeded",
+			limit:   int64(len(metricsText) - 1),
+			wantErr: promaggr.ErrBodySizeLimitExceeded,
+		},
+		{
+			name:  "exactly the limit",
+			limit: int64(len(metricsText)),
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			scraper := promaggr.NewScraper(scrapeTarget.URL, promaggr.BodySizeLimit(tt.limit))
+
+			_, err := scraper.Scrape(context.Background())
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("unexpected error: want(%v) got(%v)", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestScraperRequest(t *testing.T) {
+	t.Parallel()
+
+	scrapeTarget := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("method mismatch: want(%s) got(%s)", http.MethodPost, r.Method)
+		}
+
+		if got := r.Header.Get("X-Scope-OrgID"); got != "tenant" {
+			t.Errorf("X-Scope-OrgID header mismatch: want(%s) got(%s)", "tenant", got)
+		}
+
+		if got := r.URL.Query().Get("module"); got != "foo" {
+			t.Errorf("module parameter mismatch: want(%s) got(%s)", "foo", got)
+		}
+
+		w.Header().Set("Content-Type", string(expfmt.FmtText))
+		_, _ = io.WriteString(w, metricsText)
+	}))
+	defer scrapeTarget.Close()
+
+	scraper := promaggr.NewScraper(scrapeTarget.URL,
+		promaggr.Method(http.MethodPost),
+		promaggr.Headers(http.Header{"X-Scope-OrgID": []string{"tenant"}}),
+		promaggr.Params(url.Values{"module": []string{"foo"}}),
+	)
+
+	if _, err := scraper.Scrape(context.Background()); err != nil {
+		t.Fatalf("failed to scrape: %v", err)
+	}
+}
+
+func TestScraperProxyURL(t *testing.T) {
+	t.Parallel()
+
+	const targetURL = "http://scrape-target.example.com/metrics"
+
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.String(); got != targetURL {
+			t.Errorf("proxied URL mismatch: want(%s) got(%s)", targetURL, got)
+		}
+
+		w.Header().Set("Content-Type", string(expfmt.FmtText))
+		_, _ = io.WriteString(w, metricsText)
+	}))
+	defer proxy.Close()
+
+	proxyURL, err := url.Parse(proxy.URL)
+	if err != nil {
+		t.Fatalf("failed to parse proxy URL: %v", err)
+	}
+
+	scraper := promaggr.NewScraper(targetURL, promaggr.ProxyURL(proxyURL))
+
+	if _, err := scraper.Scrape(context.Background()); err != nil {
+		t.Fatalf("failed to scrape: %v", err)
+	}
+}
+
+func TestScraperTimeout(t *testing.T) {
+	t.Parallel()
+
+	done := make(chan struct{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-done:
+		}
+	}))
+	defer server.Close()
+	defer close(done)
+
+	scraper := promaggr.NewScraper(server.URL, promaggr.Timeout(10*time.Millisecond))
+
+	if _, err := scraper.Scrape(context.Background()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error mismatch: want(%v) got(%v)", context.DeadlineExceeded, err)
+	}
+}
+
 func newHTTPRequestCounter() *prometheus.CounterVec {
 	return prometheus.NewCounterVec(
 		prometheus.CounterOpts{
@@ -0,0 +1,137 @@
+package promaggr
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrConflictingAuthorization is returned when both basic auth and bearer token are configured.
+var ErrConflictingAuthorization = errors.New("basic auth and bearer token cannot be configured at the same time")
+
+// BasicAuth is an option available for NewScraper.
+// The request will be sent with the basic authentication.
+func BasicAuth(username, password string) ScraperOption {
+	return func(s *Scraper) {
+		s.BasicAuthUsername = username
+		s.BasicAuthPassword = password
+	}
+}
+
+// BasicAuthPasswordFile is an option available for NewScraper.
+// The request will be sent with the basic authentication, using the password read from the file.
+// The file is read again when it changes.
+func BasicAuthPasswordFile(username, passwordFile string) ScraperOption {
+	return func(s *Scraper) {
+		s.BasicAuthUsername = username
+		s.BasicAuthPasswordFile = passwordFile
+	}
+}
+
+// BearerToken is an option available for NewScraper.
+// The request will be sent with the Authorization header using the bearer token.
+func BearerToken(token string) ScraperOption {
+	return func(s *Scraper) {
+		s.BearerToken = token
+	}
+}
+
+// BearerTokenFile is an option available for NewScraper.
+// The request will be sent with the Authorization header using the bearer token read from the file.
+// The file is read again when it changes, e.g. when the Kubernetes service account token is rotated.
+func BearerTokenFile(tokenFile string) ScraperOption {
+	return func(s *Scraper) {
+		s.BearerTokenFile = tokenFile
+	}
+}
+
+// authorize sets the authorization header of the request according to the configuration of the Scraper.
+func (s *Scraper) authorize(req *http.Request) error {
+	basicAuth := s.BasicAuthUsername != "" || s.BasicAuthPassword != "" || s.BasicAuthPasswordFile != ""
+	bearerToken := s.BearerToken != "" || s.BearerTokenFile != ""
+
+	switch {
+	case basicAuth && bearerToken:
+		return ErrConflictingAuthorization
+	case basicAuth:
+		password := s.BasicAuthPassword
+
+		if s.BasicAuthPasswordFile != "" {
+			var err error
+
+			password, err = s.secrets.read(s.BasicAuthPasswordFile)
+			if err != nil {
+				return fmt.Errorf("failed to read password file: %w", err)
+			}
+		}
+
+		req.SetBasicAuth(s.BasicAuthUsername, password)
+	case bearerToken:
+		token := s.BearerToken
+
+		if s.BearerTokenFile != "" {
+			var err error
+
+			token, err = s.secrets.read(s.BearerTokenFile)
+			if err != nil {
+				return fmt.Errorf("failed to read bearer token file: %w", err)
+			}
+		}
+
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	return nil
+}
+
+// secretFiles caches the contents of secret files, such as password files and bearer token files.
+// A file is read again only when its modification time or size changes.
+// The zero value is ready to use.
+type secretFiles struct {
+	mutex sync.Mutex
+	files map[string]secretFile
+}
+
+// secretFile is the cached content of a secret file.
+type secretFile struct {
+	modTime time.Time
+	size    int64
+	content string
+}
+
+// read returns the content of the file with the surrounding whitespace trimmed.
+func (f *secretFiles) read(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if cached, ok := f.files[path]; ok && cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+		return cached.content, nil
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	if f.files == nil {
+		f.files = make(map[string]secretFile)
+	}
+
+	content := strings.TrimSpace(string(b))
+	f.files[path] = secretFile{
+		modTime: info.ModTime(),
+		size:    info.Size(),
+		content: content,
+	}
+
+	return content, nil
+}
@@ -0,0 +1,134 @@
+package promaggr_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/d-kuro/promaggr"
+	"github.com/prometheus/common/expfmt"
+)
+
+func TestScraperAuthorization(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	passwordFile := filepath.Join(dir, "password")
+	if err := os.WriteFile(passwordFile, []byte("bar\n"), 0o600); err != nil {
+		t.Fatalf("failed to write password file: %v", err)
+	}
+
+	tokenFile := filepath.Join(dir, "token")
+	if err := os.WriteFile(tokenFile, []byte("token-from-file\n"), 0o600); err != nil {
+		t.Fatalf("failed to write token file: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		opts    []promaggr.ScraperOption
+		want    string
+		wantErr error
+	}{
+		{
+			name: "basic auth",
+			opts: []promaggr.ScraperOption{promaggr.BasicAuth("foo", "bar")},
+			want: "Basic Zm9vOmJhcg==",
+		},
+		{
+			name: "basic auth with password file",
+			opts: []promaggr.ScraperOption{promaggr.BasicAuthPasswordFile("foo", passwordFile)},
+			want: "Basic Zm9vOmJhcg==",
+		},
+		{
+			name: "bearer token",
+			opts: []promaggr.ScraperOption{promaggr.BearerToken("token")},
+			want: "Bearer token",
+		},
+		{
+			name: "bearer token file",
+			opts: []promaggr.ScraperOption{promaggr.BearerTokenFile(tokenFile)},
+			want: "Bearer token-from-file",
+		},
+		{
+			name:    "conflicting authorization",
+			opts:    []promaggr.ScraperOption{promaggr.BasicAuth("foo", "bar"), promaggr.BearerToken("token")},
+			wantErr: promaggr.ErrConflictingAuthorization,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			scrapeTarget := newAuthorizationCheckServer(t, tt.want)
+			defer scrapeTarget.Close()
+
+			scraper := promaggr.NewScraper(scrapeTarget.URL, tt.opts...)
+
+			if _, err := scraper.Scrape(context.Background()); !errors.Is(err, tt.wantErr) {
+				t.Errorf("unexpected error: want(%v) got(%v)", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestScraperBearerTokenFileRotation(t *testing.T) {
+	t.Parallel()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("old-token"), 0o600); err != nil {
+		t.Fatalf("failed to write token file: %v", err)
+	}
+
+	scrapeTarget := newAuthorizationCheckServer(t, "Bearer old-token")
+	defer scrapeTarget.Close()
+
+	scraper := promaggr.NewScraper(scrapeTarget.URL, promaggr.BearerTokenFile(tokenFile))
+
+	if _, err := scraper.Scrape(context.Background()); err != nil {
+		t.Fatalf("failed to scrape: %v", err)
+	}
+
+	if err := os.WriteFile(tokenFile, []byte("new-token"), 0o600); err != nil {
+		t.Fatalf("failed to write token file: %v", err)
+	}
+
+	// Make sure that the modification time changes even on file systems with a coarse time resolution.
+	modTime := time.Now().Add(time.Minute)
+	if err := os.Chtimes(tokenFile, modTime, modTime); err != nil {
+		t.Fatalf("failed to change the modification time of the token file: %v", err)
+	}
+
+	rotatedTarget := newAuthorizationCheckServer(t, "Bearer new-token")
+	defer rotatedTarget.Close()
+
+	scraper.URL = rotatedTarget.URL
+
+	if _, err := scraper.Scrape(context.Background()); err != nil {
+		t.Fatalf("failed to scrape after the token rotation: %v", err)
+	}
+}
+
+func newAuthorizationCheckServer(t *testing.T, want string) *httptest.Server {
+	t.Helper()
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != want {
+			t.Errorf("Authorization header mismatch: want(%s) got(%s)", want, got)
+			w.WriteHeader(http.StatusUnauthorized)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", string(expfmt.FmtText))
+		_, _ = io.WriteString(w, metricsText)
+	}))
+}
@@ -0,0 +1,493 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/d-kuro/promaggr"
+	"github.com/d-kuro/promaggr/discovery"
+	"github.com/prometheus/common/model"
+	"gopkg.in/yaml.v2"
+)
+
+// ErrInvalidConfig is returned when the configuration file is invalid.
+var ErrInvalidConfig = errors.New("invalid config")
+
+var (
+	errInvalidGroupName      = errors.New("invalid group name")
+	errURLInModule           = errors.New("url must not be configured in a module")
+	errMissingURL            = errors.New("url is required")
+	errNegativeScrapeTimeout = errors.New("scrape_timeout must not be negative")
+	errConflictingAuth       = errors.New("conflicting authorization")
+	errIncompleteClientCert  = errors.New("incomplete client certificate")
+)
+
+// invalidConfigError is ErrInvalidConfig with the cause, which can be unwrapped.
+type invalidConfigError struct {
+	err error
+}
+
+func (e *invalidConfigError) Error() string {
+	return fmt.Sprintf("%v: %v", ErrInvalidConfig, e.err)
+}
+
+func (e *invalidConfigError) Unwrap() error {
+	return e.err
+}
+
+func (e *invalidConfigError) Is(target error) bool {
+	return target == ErrInvalidConfig
+}
+
+// defaultScrapeTimeout is the time limit for a scrape if no scrape_timeout is configured, as in Prometheus.
+const defaultScrapeTimeout = 10 * time.Second
+
+// groupNameRE is the pattern of the names of the groups, which are used as a path segment.
+var groupNameRE = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+
+// Config is the configuration of the promaggr server.
+type Config struct {
+	// Global holds the defaults applied to every target.
+	Global GlobalConfig `yaml:"global"`
+
+	// Targets is a list of targets to scrape metrics from.
+	Targets []TargetConfig `yaml:"targets"`
+
+	// Modules is a set of modules by name, used to scrape the targets given to the /probe endpoint.
+	Modules map[string]ModuleConfig `yaml:"modules"`
+
+	// Groups is a set of independent groups of targets by name.
+	// Each group is aggregated separately and served under "<metrics path>/<name>".
+	Groups map[string]GroupConfig `yaml:"groups"`
+}
+
+// GroupConfig is the configuration of a named group of targets.
+type GroupConfig struct {
+	// Global holds the defaults applied to every target of the group.
+	// The scrape_timeout overrides the top-level one if set, and the labels are merged into the top-level ones.
+	Global GlobalConfig `yaml:"global"`
+
+	// Targets is a list of targets of the group.
+	Targets []TargetConfig `yaml:"targets"`
+
+	// RelabelConfigs is a list of rules to rewrite the labels of each target of the group.
+	// The url of the target is given as the "__address__" label, which is only used for matching,
+	// and a target is dropped if a keep or drop rule drops it or every label including "__address__" is removed.
+	// A target left with no label once the "__" labels are removed is kept.
+	RelabelConfigs []RelabelConfig `yaml:"relabel_configs"`
+
+	// IdentifierLabel is the name of the label holding the identifier of the target added to its metrics,
+	// which is the one shown at the /targets endpoint. If not specified, no label is added.
+	IdentifierLabel model.LabelName `yaml:"identifier_label"`
+}
+
+// GlobalConfig holds the defaults applied to every target.
+type GlobalConfig struct {
+	// ScrapeTimeout is the default time limit for a scrape. The default is 10s.
+	ScrapeTimeout time.Duration `yaml:"scrape_timeout"`
+
+	// Labels are added to the metrics of every target.
+	// Labels of a target take precedence over these.
+	Labels model.LabelSet `yaml:"labels"`
+}
+
+// TargetConfig is the configuration of a target to scrape metrics from.
+type TargetConfig struct {
+	URL             string            `yaml:"url"`
+	Labels          model.LabelSet    `yaml:"labels"`
+	ScrapeTimeout   time.Duration     `yaml:"scrape_timeout"`
+	Match           []string          `yaml:"match"`
+	HonorLabels     bool              `yaml:"honor_labels"`
+	HonorTimestamps *bool             `yaml:"honor_timestamps"`
+	Method          string            `yaml:"method"`
+	Headers         map[string]string `yaml:"headers"`
+	Params          url.Values        `yaml:"params"`
+	ProxyURL        string            `yaml:"proxy_url"`
+	UnixSocket      string            `yaml:"unix_socket"`
+
+	BasicAuth       *BasicAuthConfig `yaml:"basic_auth"`
+	BearerToken     string           `yaml:"bearer_token"`
+	BearerTokenFile string           `yaml:"bearer_token_file"`
+	TLSConfig       *TLSConfig       `yaml:"tls_config"`
+
+	SampleLimit           int   `yaml:"sample_limit"`
+	LabelLimit            int   `yaml:"label_limit"`
+	LabelNameLengthLimit  int   `yaml:"label_name_length_limit"`
+	LabelValueLengthLimit int   `yaml:"label_value_length_limit"`
+	BodySizeLimit         int64 `yaml:"body_size_limit"`
+}
+
+// ModuleConfig is the configuration of a module used to scrape the targets given to the /probe endpoint.
+// It has the same fields as TargetConfig except the url, which is built from the target.
+type ModuleConfig struct {
+	// Scheme is the scheme used to scrape a target given without a scheme. The default is "http".
+	Scheme string `yaml:"scheme"`
+
+	// MetricsPath is the path used to scrape a target given without a scheme. The default is "/metrics".
+	MetricsPath string `yaml:"metrics_path"`
+
+	TargetConfig `yaml:",inline"`
+}
+
+// BasicAuthConfig is the configuration of the basic authentication of a target.
+type BasicAuthConfig struct {
+	Username     string `yaml:"username"`
+	Password     string `yaml:"password"`
+	PasswordFile string `yaml:"password_file"`
+}
+
+// TLSConfig is the configuration of the TLS connection to a target.
+type TLSConfig struct {
+	CAFile             string `yaml:"ca_file"`
+	CertFile           string `yaml:"cert_file"`
+	KeyFile            string `yaml:"key_file"`
+	ServerName         string `yaml:"server_name"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+}
+
+// LoadFile reads and validates the configuration file at the given path.
+func LoadFile(path string) (*Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+
+	cfg, err := Load(b)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config file %s: %w", path, err)
+	}
+
+	return cfg, nil
+}
+
+// Load parses and validates the given YAML configuration.
+func Load(b []byte) (*Config, error) {
+	cfg := &Config{}
+
+	if err := yaml.UnmarshalStrict(b, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+func (c *Config) validate() error {
+	if err := c.validateFields(); err != nil {
+		return &invalidConfigError{err: err}
+	}
+
+	return nil
+}
+
+func (c *Config) validateFields() error {
+	if err := validateTargets(c.Global, c.Targets); err != nil {
+		return err
+	}
+
+	for name, group := range c.Groups {
+		if !groupNameRE.MatchString(name) || name == "." || name == ".." {
+			return fmt.Errorf("%w %q", errInvalidGroupName, name)
+		}
+
+		if err := group.validate(); err != nil {
+			return fmt.Errorf("groups[%s]: %w", name, err)
+		}
+	}
+
+	for name, module := range c.Modules {
+		if err := module.validate(); err != nil {
+			return fmt.Errorf("modules[%s]: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+func validateTargets(global GlobalConfig, targets []TargetConfig) error {
+	if global.ScrapeTimeout < 0 {
+		return fmt.Errorf("global %w", errNegativeScrapeTimeout)
+	}
+
+	if err := global.Labels.Validate(); err != nil {
+		return fmt.Errorf("global labels: %w", err)
+	}
+
+	for i := range targets {
+		if err := targets[i].validate(); err != nil {
+			return fmt.Errorf("targets[%d]: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+func (c *GroupConfig) validate() error {
+	if err := validateTargets(c.Global, c.Targets); err != nil {
+		return err
+	}
+
+	for i := range c.RelabelConfigs {
+		if err := c.RelabelConfigs[i].validate(); err != nil {
+			return fmt.Errorf("relabel_configs[%d]: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+func (c *ModuleConfig) validate() error {
+	if c.URL != "" {
+		return errURLInModule
+	}
+
+	return c.validateOptions()
+}
+
+func (c *TargetConfig) validate() error {
+	if c.URL == "" {
+		return errMissingURL
+	}
+
+	if _, err := url.Parse(c.URL); err != nil {
+		return fmt.Errorf("invalid url: %w", err)
+	}
+
+	return c.validateOptions()
+}
+
+// validateOptions validates the fields other than the url.
+func (c *TargetConfig) validateOptions() error {
+	if c.ProxyURL != "" {
+		if _, err := url.Parse(c.ProxyURL); err != nil {
+			return fmt.Errorf("invalid proxy_url: %w", err)
+		}
+	}
+
+	if err := c.Labels.Validate(); err != nil {
+		return fmt.Errorf("labels: %w", err)
+	}
+
+	if c.ScrapeTimeout < 0 {
+		return errNegativeScrapeTimeout
+	}
+
+	if c.BasicAuth != nil && (c.BearerToken != "" || c.BearerTokenFile != "") {
+		return fmt.Errorf("%w: at most one of basic_auth, bearer_token and bearer_token_file must be configured", errConflictingAuth)
+	}
+
+	if c.BearerToken != "" && c.BearerTokenFile != "" {
+		return fmt.Errorf("%w: at most one of bearer_token and bearer_token_file must be configured", errConflictingAuth)
+	}
+
+	if c.BasicAuth != nil && c.BasicAuth.Password != "" && c.BasicAuth.PasswordFile != "" {
+		return fmt.Errorf("%w: at most one of basic_auth password and password_file must be configured", errConflictingAuth)
+	}
+
+	if c.TLSConfig != nil && (c.TLSConfig.CertFile == "") != (c.TLSConfig.KeyFile == "") {
+		return fmt.Errorf("%w: tls_config cert_file and key_file must be configured together", errIncompleteClientCert)
+	}
+
+	return nil
+}
+
+// Scrapers builds the Scrapers of the configured targets.
+// The given options are applied to every Scraper before the target configuration.
+func (c *Config) Scrapers(opts ...promaggr.ScraperOption) ([]*promaggr.Scraper, error) {
+	return buildScrapers(c.Global, c.Targets, nil, opts...)
+}
+
+// GroupScrapers builds the Scrapers of the targets of each group by the name of the group.
+// The targets dropped by the relabel_configs of the group are skipped.
+// The given options are applied to every Scraper before the target configuration.
+func (c *Config) GroupScrapers(opts ...promaggr.ScraperOption) (map[string][]*promaggr.Scraper, error) {
+	groups := make(map[string][]*promaggr.Scraper, len(c.Groups))
+
+	for name, group := range c.Groups {
+		relabel, err := relabelFunc(group.RelabelConfigs)
+		if err != nil {
+			return nil, fmt.Errorf("groups[%s]: %w", name, err)
+		}
+
+		scrapers, err := buildScrapers(c.Global.merge(group.Global), group.Targets, relabel, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("groups[%s]: %w", name, err)
+		}
+
+		groups[name] = scrapers
+	}
+
+	return groups, nil
+}
+
+func buildScrapers(global GlobalConfig, targets []TargetConfig, relabel discovery.RelabelFunc, opts ...promaggr.ScraperOption) ([]*promaggr.Scraper, error) {
+	scrapers := make([]*promaggr.Scraper, 0, len(targets))
+
+	for i := range targets {
+		target, targetGlobal := targets[i], global
+
+		if relabel != nil {
+			labels, ok := relabelTarget(relabel, target.URL, global.Labels.Merge(target.Labels))
+			if !ok {
+				continue
+			}
+
+			// The relabeled labels already include the global ones.
+			target.Labels, targetGlobal.Labels = labels, nil
+		}
+
+		scraper, err := target.scraper(targetGlobal, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("targets[%d]: %w", i, err)
+		}
+
+		scrapers = append(scrapers, scraper)
+	}
+
+	return scrapers, nil
+}
+
+// merge returns the defaults overridden by the given ones.
+func (g GlobalConfig) merge(override GlobalConfig) GlobalConfig {
+	if override.ScrapeTimeout > 0 {
+		g.ScrapeTimeout = override.ScrapeTimeout
+	}
+
+	g.Labels = g.Labels.Merge(override.Labels)
+
+	return g
+}
+
+// ProbeModules builds the modules used to scrape the targets given to the /probe endpoint.
+// The given options are applied to every Scraper before the module configuration.
+func (c *Config) ProbeModules(opts ...promaggr.ScraperOption) map[string]promaggr.ProbeModule {
+	modules := make(map[string]promaggr.ProbeModule, len(c.Modules))
+
+	for name, module := range c.Modules {
+		module := module
+
+		modules[name] = func(target string) ([]*promaggr.Scraper, error) {
+			return module.scrapers(target, c.Global, opts...)
+		}
+	}
+
+	return modules
+}
+
+// scrapers builds the Scraper of the target.
+// If the target has no scheme, e.g. "localhost:9100", the url is built with the Scheme and the MetricsPath.
+func (c *ModuleConfig) scrapers(target string, global GlobalConfig, opts ...promaggr.ScraperOption) ([]*promaggr.Scraper, error) {
+	targetURL, err := discovery.TargetURL(model.LabelSet{
+		discovery.AddressLabel:     model.LabelValue(target),
+		discovery.SchemeLabel:      model.LabelValue(c.Scheme),
+		discovery.MetricsPathLabel: model.LabelValue(c.MetricsPath),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid target: %w", err)
+	}
+
+	if _, err = url.Parse(targetURL); err != nil {
+		return nil, fmt.Errorf("invalid target: %w", err)
+	}
+
+	config := c.TargetConfig
+	config.URL = targetURL
+
+	scraper, err := config.scraper(global, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return []*promaggr.Scraper{scraper}, nil
+}
+
+// honorTimestamps returns the honor_timestamps of the target, which defaults to true as in Prometheus.
+func (c *TargetConfig) honorTimestamps() bool {
+	return c.HonorTimestamps == nil || *c.HonorTimestamps
+}
+
+func (c *TargetConfig) scraper(global GlobalConfig, opts ...promaggr.ScraperOption) (*promaggr.Scraper, error) {
+	opts = append(opts,
+		promaggr.Labels(global.Labels.Merge(c.Labels)),
+		promaggr.Method(c.Method),
+		promaggr.Params(c.Params),
+		promaggr.HonorLabels(c.HonorLabels),
+		promaggr.ExportConflictingLabels(true),
+		promaggr.HonorTimestamps(c.honorTimestamps()),
+		promaggr.SampleLimit(c.SampleLimit),
+		promaggr.LabelLimit(c.LabelLimit),
+		promaggr.LabelNameLengthLimit(c.LabelNameLengthLimit),
+		promaggr.LabelValueLengthLimit(c.LabelValueLengthLimit),
+		promaggr.BodySizeLimit(c.BodySizeLimit),
+	)
+
+	if timeout := c.ScrapeTimeout; timeout > 0 {
+		opts = append(opts, promaggr.Timeout(timeout))
+	} else if global.ScrapeTimeout > 0 {
+		opts = append(opts, promaggr.Timeout(global.ScrapeTimeout))
+	} else {
+		opts = append(opts, promaggr.Timeout(defaultScrapeTimeout))
+	}
+
+	if len(c.Match) > 0 {
+		opts = append(opts, promaggr.Federate(c.Match...))
+	}
+
+	if len(c.Headers) > 0 {
+		headers := make(http.Header, len(c.Headers))
+		for name, value := range c.Headers {
+			headers.Set(name, value)
+		}
+
+		opts = append(opts, promaggr.Headers(headers))
+	}
+
+	if c.ProxyURL != "" {
+		proxyURL, err := url.Parse(c.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy_url: %w", err)
+		}
+
+		opts = append(opts, promaggr.ProxyURL(proxyURL))
+	}
+
+	if c.UnixSocket != "" {
+		opts = append(opts, promaggr.UnixSocket(c.UnixSocket))
+	}
+
+	if auth := c.BasicAuth; auth != nil {
+		if auth.PasswordFile != "" {
+			opts = append(opts, promaggr.BasicAuthPasswordFile(auth.Username, auth.PasswordFile))
+		} else {
+			opts = append(opts, promaggr.BasicAuth(auth.Username, auth.Password))
+		}
+	}
+
+	if c.BearerToken != "" {
+		opts = append(opts, promaggr.BearerToken(c.BearerToken))
+	}
+
+	if c.BearerTokenFile != "" {
+		opts = append(opts, promaggr.BearerTokenFile(c.BearerTokenFile))
+	}
+
+	if tls := c.TLSConfig; tls != nil {
+		opts = append(opts, promaggr.TLS(promaggr.TLSConfig{
+			CAFile:             tls.CAFile,
+			CertFile:           tls.CertFile,
+			KeyFile:            tls.KeyFile,
+			ServerName:         tls.ServerName,
+			InsecureSkipVerify: tls.InsecureSkipVerify,
+		}))
+	}
+
+	return promaggr.NewScraper(c.URL, opts...), nil
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/common/model"
+)
+
+func TestLoadFile(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := LoadFile("testdata/promaggr.yml")
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	want := &Config{
+		Global: GlobalConfig{
+			ScrapeTimeout: 10 * time.Second,
+			Labels:        model.LabelSet{"cluster": "tokyo"},
+		},
+		Targets: []TargetConfig{
+			{
+				URL:    "http://localhost:9100/metrics",
+				Labels: model.LabelSet{"job": "node"},
+			},
+			{
+				URL:             "https://localhost:9090/federate",
+				ScrapeTimeout:   5 * time.Second,
+				Match:           []string{`{job="prometheus"}`},
+				HonorLabels:     true,
+				HonorTimestamps: boolPointer(false),
+				BasicAuth: &BasicAuthConfig{
+					Username:     "admin",
+					PasswordFile: "/etc/promaggr/password",
+				},
+				TLSConfig: &TLSConfig{
+					CAFile:             "/etc/promaggr/ca.crt",
+					InsecureSkipVerify: true,
+				},
+				SampleLimit: 1000,
+			},
+		},
+		Modules: map[string]ModuleConfig{
+			"node": {
+				MetricsPath: "/node/metrics",
+				TargetConfig: TargetConfig{
+					ScrapeTimeout: 3 * time.Second,
+					Labels:        model.LabelSet{"job": "node"},
+				},
+			},
+		},
+		Groups: map[string]GroupConfig{
+			"gpu-nodes": {
+				Global: GlobalConfig{Labels: model.LabelSet{"pool": "gpu"}},
+				Targets: []TargetConfig{
+					{URL: "http://gpu-node1:9100/metrics"},
+					{URL: "http://gpu-node2:9100/metrics", Labels: model.LabelSet{"maintenance": "true"}},
+				},
+				RelabelConfigs: []RelabelConfig{
+					{SourceLabels: model.LabelNames{"maintenance"}, Regex: "true", Action: RelabelDrop},
+					{SourceLabels: model.LabelNames{"__address__"}, Regex: "http://([^:]+):.*", TargetLabel: "node"},
+				},
+				IdentifierLabel: "source",
+			},
+		},
+	}
+
+	if diff := cmp.Diff(want, cfg); diff != "" {
+		t.Errorf("config mismatch (-want +got):\n%s", diff)
+	}
+
+	scrapers, err := cfg.Scrapers()
+	if err != nil {
+		t.Fatalf("failed to build scrapers: %v", err)
+	}
+
+	if got := scrapers[0].Timeout; got != 10*time.Second {
+		t.Errorf("timeout mismatch: want(%s) got(%s)", 10*time.Second, got)
+	}
+
+	if diff := cmp.Diff(model.LabelSet{"cluster": "tokyo", "job": "node"}, scrapers[0].Labels); diff != "" {
+		t.Errorf("labels mismatch (-want +got):\n%s", diff)
+	}
+
+	if got := scrapers[1].Timeout; got != 5*time.Second {
+		t.Errorf("timeout mismatch: want(%s) got(%s)", 5*time.Second, got)
+	}
+
+	// The timestamps are honored unless honor_timestamps is false.
+	if scrapers[0].DropTimestamps || !scrapers[1].DropTimestamps {
+		t.Errorf("drop timestamps mismatch: want(false, true) got(%t, %t)", scrapers[0].DropTimestamps, scrapers[1].DropTimestamps)
+	}
+
+	groups, err := cfg.GroupScrapers()
+	if err != nil {
+		t.Fatalf("failed to build group scrapers: %v", err)
+	}
+
+	gpuNodes := groups["gpu-nodes"]
+	if len(gpuNodes) != 1 {
+		t.Fatalf("number of scrapers mismatch: want(1) got(%d)", len(gpuNodes))
+	}
+
+	if diff := cmp.Diff(model.LabelSet{"cluster": "tokyo", "pool": "gpu", "node": "gpu-node1"}, gpuNodes[0].Labels); diff != "" {
+		t.Errorf("labels mismatch (-want +got):\n%s", diff)
+	}
+
+	if got := gpuNodes[0].Timeout; got != 10*time.Second {
+		t.Errorf("timeout mismatch: want(%s) got(%s)", 10*time.Second, got)
+	}
+
+	probed, err := cfg.ProbeModules()["node"]("localhost:9100")
+	if err != nil {
+		t.Fatalf("failed to build probe scrapers: %v", err)
+	}
+
+	if got := probed[0].URL; got != "http://localhost:9100/node/metrics" {
+		t.Errorf("url mismatch: want(http://localhost:9100/node/metrics) got(%s)", got)
+	}
+
+	if got := probed[0].Timeout; got != 3*time.Second {
+		t.Errorf("timeout mismatch: want(%s) got(%s)", 3*time.Second, got)
+	}
+}
+
+func TestLoadDefaultScrapeTimeout(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := Load([]byte("targets:\n  - url: http://localhost:9100/metrics\n"))
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	scrapers, err := cfg.Scrapers()
+	if err != nil {
+		t.Fatalf("failed to build scrapers: %v", err)
+	}
+
+	if got := scrapers[0].Timeout; got != defaultScrapeTimeout {
+		t.Errorf("timeout mismatch: want(%s) got(%s)", defaultScrapeTimeout, got)
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		config    string
+		wantErr   error
+		wantCause error
+	}{
+		{
+			name:   "unknown field",
+			config: "targets:\n  - uri: http://localhost:9100/metrics\n",
+		},
+		{
+			name:      "missing url",
+			config:    "targets:\n  - labels:\n      job: node\n",
+			wantErr:   ErrInvalidConfig,
+			wantCause: errMissingURL,
+		},
+		{
+			name:   "invalid label name",
+			config: "global:\n  labels:\n    0job: node\n",
+		},
+		{
+			name:      "negative timeout",
+			config:    "targets:\n  - url: http://localhost:9100/metrics\n    scrape_timeout: -1s\n",
+			wantErr:   ErrInvalidConfig,
+			wantCause: errNegativeScrapeTimeout,
+		},
+		{
+			name: "conflicting authorization",
+			config: "targets:\n  - url: http://localhost:9100/metrics\n" +
+				"    bearer_token: token\n    basic_auth:\n      username: admin\n",
+			wantErr:   ErrInvalidConfig,
+			wantCause: errConflictingAuth,
+		},
+		{
+			name: "incomplete client certificate",
+			config: "targets:\n  - url: http://localhost:9100/metrics\n" +
+				"    tls_config:\n      cert_file: /etc/promaggr/client.crt\n",
+			wantErr:   ErrInvalidConfig,
+			wantCause: errIncompleteClientCert,
+		},
+		{
+			name:      "invalid group name",
+			config:    "groups:\n  cluster/a:\n    targets: []\n",
+			wantErr:   ErrInvalidConfig,
+			wantCause: errInvalidGroupName,
+		},
+		{
+			name:      "missing url in group",
+			config:    "groups:\n  cluster-a:\n    targets:\n      - labels:\n          job: node\n",
+			wantErr:   ErrInvalidConfig,
+			wantCause: errMissingURL,
+		},
+		{
+			name:    "invalid relabel regex in group",
+			config:  "groups:\n  cluster-a:\n    relabel_configs:\n      - source_labels: [job]\n        regex: '('\n        action: keep\n",
+			wantErr: ErrInvalidConfig,
+		},
+		{
+			name:      "unknown relabel action in group",
+			config:    "groups:\n  cluster-a:\n    relabel_configs:\n      - action: hashmod\n",
+			wantErr:   ErrInvalidConfig,
+			wantCause: errUnknownRelabelAction,
+		},
+		{
+			name:      "missing relabel source labels in group",
+			config:    "groups:\n  cluster-a:\n    relabel_configs:\n      - action: drop\n",
+			wantErr:   ErrInvalidConfig,
+			wantCause: errMissingSourceLabels,
+		},
+		{
+			name:      "invalid relabel target label in group",
+			config:    "groups:\n  cluster-a:\n    relabel_configs:\n      - source_labels: [job]\n",
+			wantErr:   ErrInvalidConfig,
+			wantCause: errInvalidTargetLabel,
+		},
+		{
+			name:   "invalid identifier label in group",
+			config: "groups:\n  cluster-a:\n    identifier_label: 0source\n",
+		},
+		{
+			name:      "url in module",
+			config:    "modules:\n  node:\n    url: http://localhost:9100/metrics\n",
+			wantErr:   ErrInvalidConfig,
+			wantCause: errURLInModule,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := Load([]byte(tt.config))
+			if err == nil {
+				t.Fatal("expected error, but got nil")
+			}
+
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("error mismatch: want(%v) got(%v)", tt.wantErr, err)
+			}
+
+			if tt.wantCause != nil && !errors.Is(err, tt.wantCause) {
+				t.Errorf("cause mismatch: want(%v) got(%v)", tt.wantCause, err)
+			}
+		})
+	}
+}
+
+func boolPointer(b bool) *bool {
+	return &b
+}
@@ -0,0 +1,98 @@
+// Command promaggr serves the metrics aggregated from the targets listed in a YAML configuration file.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/go-logr/stdr"
+)
+
+const (
+	shutdownTimeout   = 30 * time.Second
+	readHeaderTimeout = 10 * time.Second
+)
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	flags := flag.NewFlagSet("promaggr", flag.ContinueOnError)
+
+	configFile := flags.String("config.file", "promaggr.yml", "Path to the configuration file.")
+	listenAddress := flags.String("web.listen-address", ":9091", "Address on which to expose metrics.")
+	metricsPath := flags.String("web.telemetry-path", "/metrics", "Path under which to expose metrics.")
+
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("failed to parse flags: %w", err)
+	}
+
+	logger := stdr.New(log.New(os.Stderr, "", log.LstdFlags))
+
+	s, err := newServer(*configFile, logger)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	srv := &http.Server{
+		Addr:              *listenAddress,
+		Handler:           s.handler(*metricsPath),
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+
+	errCh := make(chan error, 1)
+
+	go func() {
+		logger.Info("listening", "address", *listenAddress)
+		errCh <- srv.ListenAndServe()
+	}()
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	for running := true; running; {
+		select {
+		case err := <-errCh:
+			return fmt.Errorf("failed to serve: %w", err)
+		case <-hup:
+			if err := s.reload(); err != nil {
+				logger.Error(err, "failed to reload config", "file", *configFile)
+			} else {
+				logger.Info("reloaded config", "file", *configFile)
+			}
+		case <-ctx.Done():
+			running = false
+		}
+	}
+
+	logger.Info("shutting down")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("failed to shut down: %w", err)
+	}
+
+	if err := <-errCh; !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("failed to serve: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/d-kuro/promaggr/discovery"
+	"github.com/prometheus/common/model"
+)
+
+// RelabelAction is the action of a relabel rule.
+type RelabelAction string
+
+const (
+	// RelabelReplace sets the target_label to the replacement if the regex matches the source labels.
+	RelabelReplace RelabelAction = "replace"
+
+	// RelabelKeep drops the target if the regex does not match the source labels.
+	RelabelKeep RelabelAction = "keep"
+
+	// RelabelDrop drops the target if the regex matches the source labels.
+	RelabelDrop RelabelAction = "drop"
+
+	// RelabelLabelKeep removes the labels whose names do not match the regex.
+	RelabelLabelKeep RelabelAction = "labelkeep"
+
+	// RelabelLabelDrop removes the labels whose names match the regex.
+	RelabelLabelDrop RelabelAction = "labeldrop"
+
+	defaultRelabelSeparator   = ";"
+	defaultRelabelRegex       = "(.*)"
+	defaultRelabelReplacement = "$1"
+)
+
+var (
+	errInvalidTargetLabel   = errors.New("invalid target_label")
+	errMissingSourceLabels  = errors.New("source_labels are required")
+	errUnknownRelabelAction = errors.New("unknown action")
+)
+
+// RelabelConfig is a rule to rewrite the labels of a target, like the relabel_config of Prometheus.
+type RelabelConfig struct {
+	// SourceLabels is a list of labels whose values are joined by the Separator and matched against the Regex.
+	SourceLabels model.LabelNames `yaml:"source_labels"`
+
+	// Separator is the separator of the values of the SourceLabels. The default is ";".
+	Separator string `yaml:"separator"`
+
+	// Regex is the regular expression matched against the joined values, which is anchored at both ends.
+	// The default is "(.*)".
+	Regex string `yaml:"regex"`
+
+	// TargetLabel is the label set by the replace action.
+	TargetLabel model.LabelName `yaml:"target_label"`
+
+	// Replacement is the value set by the replace action, which may refer to the capture groups of the Regex.
+	// The default is "$1". If the replacement is empty, the TargetLabel is removed.
+	Replacement *string `yaml:"replacement"`
+
+	// Action is the action of the rule. The default is "replace".
+	Action RelabelAction `yaml:"action"`
+}
+
+// relabelRule is a RelabelConfig with the defaults applied and the regex compiled.
+type relabelRule struct {
+	sourceLabels model.LabelNames
+	separator    string
+	regex        *regexp.Regexp
+	targetLabel  model.LabelName
+	replacement  string
+	action       RelabelAction
+}
+
+func (c *RelabelConfig) validate() error {
+	_, err := c.rule()
+
+	return err
+}
+
+// rule returns the relabelRule of the configuration.
+func (c *RelabelConfig) rule() (*relabelRule, error) {
+	rule := &relabelRule{
+		sourceLabels: c.SourceLabels,
+		separator:    c.Separator,
+		targetLabel:  c.TargetLabel,
+		replacement:  defaultRelabelReplacement,
+		action:       c.Action,
+	}
+
+	if rule.separator == "" {
+		rule.separator = defaultRelabelSeparator
+	}
+
+	if c.Replacement != nil {
+		rule.replacement = *c.Replacement
+	}
+
+	if rule.action == "" {
+		rule.action = RelabelReplace
+	}
+
+	pattern := c.Regex
+	if pattern == "" {
+		pattern = defaultRelabelRegex
+	}
+
+	regex, err := regexp.Compile("^(?:" + pattern + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex: %w", err)
+	}
+
+	rule.regex = regex
+
+	switch rule.action {
+	case RelabelReplace:
+		if !rule.targetLabel.IsValid() {
+			return nil, fmt.Errorf("%w %q", errInvalidTargetLabel, rule.targetLabel)
+		}
+	case RelabelKeep, RelabelDrop:
+		if len(rule.sourceLabels) == 0 {
+			return nil, fmt.Errorf("%w for the %s action", errMissingSourceLabels, rule.action)
+		}
+	case RelabelLabelKeep, RelabelLabelDrop:
+	default:
+		return nil, fmt.Errorf("%w %q", errUnknownRelabelAction, rule.action)
+	}
+
+	return rule, nil
+}
+
+// apply applies the rule to the labels in place. It reports whether the target is kept.
+func (r *relabelRule) apply(labels model.LabelSet) bool {
+	values := make([]string, 0, len(r.sourceLabels))
+	for _, name := range r.sourceLabels {
+		values = append(values, string(labels[name]))
+	}
+
+	value := strings.Join(values, r.separator)
+
+	switch r.action {
+	case RelabelReplace:
+		match := r.regex.FindStringSubmatchIndex(value)
+		if match == nil {
+			return true
+		}
+
+		replaced := r.regex.ExpandString(nil, r.replacement, value, match)
+		if len(replaced) == 0 {
+			delete(labels, r.targetLabel)
+		} else {
+			labels[r.targetLabel] = model.LabelValue(replaced)
+		}
+	case RelabelKeep:
+		return r.regex.MatchString(value)
+	case RelabelDrop:
+		return !r.regex.MatchString(value)
+	case RelabelLabelKeep, RelabelLabelDrop:
+		for name := range labels {
+			if r.regex.MatchString(string(name)) != (r.action == RelabelLabelKeep) {
+				delete(labels, name)
+			}
+		}
+	}
+
+	return true
+}
+
+// relabelFunc returns the discovery.RelabelFunc applying the rules in order.
+// If there is no rule, nil is returned.
+func relabelFunc(configs []RelabelConfig) (discovery.RelabelFunc, error) {
+	if len(configs) == 0 {
+		return nil, nil
+	}
+
+	rules := make([]*relabelRule, 0, len(configs))
+
+	for i := range configs {
+		rule, err := configs[i].rule()
+		if err != nil {
+			return nil, fmt.Errorf("relabel_configs[%d]: %w", i, err)
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return func(labels model.LabelSet) model.LabelSet {
+		labels = labels.Clone()
+
+		for _, rule := range rules {
+			if !rule.apply(labels) {
+				return nil
+			}
+		}
+
+		return labels
+	}, nil
+}
+
+// relabelTarget applies the relabel function to the labels of the target.
+// The url of the target is given as the AddressLabel,
+// and the labels prefixed with "__" are removed after relabeling.
+// It reports false if the target is dropped by a rule or all the labels, including the AddressLabel, are removed,
+// which is checked before the "__" labels are removed so that a target without labels is kept.
+func relabelTarget(relabel discovery.RelabelFunc, targetURL string, labels model.LabelSet) (model.LabelSet, bool) {
+	input := labels.Clone()
+	input[discovery.AddressLabel] = model.LabelValue(targetURL)
+
+	output := relabel(input)
+	if len(output) == 0 {
+		return nil, false
+	}
+
+	for name := range output {
+		if strings.HasPrefix(string(name), model.ReservedLabelPrefix) {
+			delete(output, name)
+		}
+	}
+
+	return output, true
+}
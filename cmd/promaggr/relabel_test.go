@@ -0,0 +1,151 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/common/model"
+)
+
+func TestRelabelFunc(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		configs []RelabelConfig
+		labels  model.LabelSet
+		want    model.LabelSet
+	}{
+		{
+			name: "replace",
+			configs: []RelabelConfig{
+				{SourceLabels: model.LabelNames{"job", "zone"}, Regex: "(.+);(.+)", TargetLabel: "name", Replacement: stringPointer("$1-$2")},
+			},
+			labels: model.LabelSet{"job": "node", "zone": "a"},
+			want:   model.LabelSet{"job": "node", "zone": "a", "name": "node-a"},
+		},
+		{
+			name: "replace with empty value",
+			configs: []RelabelConfig{
+				{TargetLabel: "zone", Replacement: stringPointer("")},
+			},
+			labels: model.LabelSet{"job": "node", "zone": "a"},
+			want:   model.LabelSet{"job": "node"},
+		},
+		{
+			name: "keep",
+			configs: []RelabelConfig{
+				{SourceLabels: model.LabelNames{"job"}, Regex: "node", Action: RelabelKeep},
+			},
+			labels: model.LabelSet{"job": "prometheus"},
+			want:   nil,
+		},
+		{
+			name: "drop",
+			configs: []RelabelConfig{
+				{SourceLabels: model.LabelNames{"job"}, Regex: "node", Action: RelabelDrop},
+			},
+			labels: model.LabelSet{"job": "prometheus"},
+			want:   model.LabelSet{"job": "prometheus"},
+		},
+		{
+			name: "labeldrop",
+			configs: []RelabelConfig{
+				{Regex: "zone|rack", Action: RelabelLabelDrop},
+			},
+			labels: model.LabelSet{"job": "node", "zone": "a", "rack": "1"},
+			want:   model.LabelSet{"job": "node"},
+		},
+		{
+			name: "labelkeep",
+			configs: []RelabelConfig{
+				{Regex: "job", Action: RelabelLabelKeep},
+			},
+			labels: model.LabelSet{"job": "node", "zone": "a"},
+			want:   model.LabelSet{"job": "node"},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			relabel, err := relabelFunc(tt.configs)
+			if err != nil {
+				t.Fatalf("failed to build relabel func: %v", err)
+			}
+
+			got := relabel(tt.labels)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("labels mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRelabelTarget(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		configs  []RelabelConfig
+		labels   model.LabelSet
+		want     model.LabelSet
+		wantKeep bool
+	}{
+		{
+			name: "target without labels",
+			configs: []RelabelConfig{
+				{SourceLabels: model.LabelNames{"job"}, Regex: "node", Action: RelabelDrop},
+			},
+			labels:   nil,
+			want:     model.LabelSet{},
+			wantKeep: true,
+		},
+		{
+			name: "address label",
+			configs: []RelabelConfig{
+				{SourceLabels: model.LabelNames{"__address__"}, Regex: "http://([^:]+):.*", TargetLabel: "node"},
+				{TargetLabel: "__tmp", Replacement: stringPointer("x")},
+			},
+			labels:   model.LabelSet{"job": "node"},
+			want:     model.LabelSet{"job": "node", "node": "node1"},
+			wantKeep: true,
+		},
+		{
+			name: "all labels removed",
+			configs: []RelabelConfig{
+				{Regex: ".*", Action: RelabelLabelDrop},
+			},
+			labels:   model.LabelSet{"job": "node"},
+			want:     nil,
+			wantKeep: false,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			relabel, err := relabelFunc(tt.configs)
+			if err != nil {
+				t.Fatalf("failed to build relabel func: %v", err)
+			}
+
+			got, keep := relabelTarget(relabel, "http://node1:9100/metrics", tt.labels)
+			if keep != tt.wantKeep {
+				t.Errorf("keep mismatch: want(%t) got(%t)", tt.wantKeep, keep)
+			}
+
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("labels mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func stringPointer(s string) *string {
+	return &s
+}
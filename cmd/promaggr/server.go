@@ -0,0 +1,312 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/d-kuro/promaggr"
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/common/model"
+)
+
+// server serves the metrics aggregated from the configured targets.
+type server struct {
+	configFile string
+	logger     logr.Logger
+	registry   *prometheus.Registry
+	metrics    *promaggr.ScrapeMetrics
+
+	reloadSuccessful prometheus.Gauge
+
+	collector *promaggr.Collector
+
+	// mutex guards the fields replaced on reload.
+	mutex        sync.RWMutex
+	probeHandler *promaggr.ProbeHandler
+	groups       map[string]*group
+
+	// reloadMutex serializes reloads.
+	reloadMutex sync.Mutex
+}
+
+// group serves the metrics aggregated from the targets of a named group.
+type group struct {
+	collector       *promaggr.Collector
+	handler         http.Handler
+	identifierLabel model.LabelName
+}
+
+// loaded holds what is built from the configuration file.
+type loaded struct {
+	scrapers []*promaggr.Scraper
+	groups   map[string]loadedGroup
+	modules  map[string]promaggr.ProbeModule
+}
+
+// loadedGroup holds what is built from the configuration of a group.
+type loadedGroup struct {
+	scrapers        []*promaggr.Scraper
+	identifierLabel model.LabelName
+}
+
+// newServer creates a server from the configuration file at the given path.
+// It fails if the configuration cannot be loaded.
+func newServer(configFile string, logger logr.Logger) (*server, error) {
+	s := &server{
+		configFile: configFile,
+		logger:     logger,
+		registry:   prometheus.NewRegistry(),
+		metrics:    promaggr.NewScrapeMetrics(),
+		reloadSuccessful: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "promaggr_config_last_reload_successful",
+			Help: "Whether the last configuration reload attempt was successful.",
+		}),
+		collector: promaggr.NewCollector(nil, promaggr.Logger(logger)),
+	}
+
+	if err := s.registry.Register(s.metrics); err != nil {
+		return nil, fmt.Errorf("failed to register scrape metrics: %w", err)
+	}
+
+	if err := s.registry.Register(s.reloadSuccessful); err != nil {
+		return nil, fmt.Errorf("failed to register reload metrics: %w", err)
+	}
+
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// reload loads the configuration file and replaces the Scrapers of the collectors
+// and the probe modules with ones built from it.
+// If the configuration is invalid, the current Scrapers and modules are kept.
+func (s *server) reload() error {
+	s.reloadMutex.Lock()
+	defer s.reloadMutex.Unlock()
+
+	l, err := s.load()
+	if err != nil {
+		s.reloadSuccessful.Set(0)
+
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	removed := s.targetURLs()
+
+	s.collector.ReplaceScrapers(l.scrapers)
+	s.probeHandler = promaggr.NewProbeHandler(l.modules, promaggr.ProbeHandlerLogger(s.logger))
+	s.groups = s.reloadGroups(l.groups)
+
+	// The scrape metrics of the targets which are no longer scraped are deleted.
+	for url := range s.targetURLs() {
+		delete(removed, url)
+	}
+
+	for url := range removed {
+		s.metrics.DeleteTarget(url)
+	}
+
+	s.reloadSuccessful.Set(1)
+
+	return nil
+}
+
+// reloadGroups returns the groups with the given Scrapers.
+// The collectors of the existing groups are reused unless their merge options are changed,
+// and the removed groups are dropped.
+// The caller must hold the write lock of the mutex.
+func (s *server) reloadGroups(loadedGroups map[string]loadedGroup) map[string]*group {
+	groups := make(map[string]*group, len(loadedGroups))
+
+	for name, l := range loadedGroups {
+		g, ok := s.groups[name]
+		if !ok || g.identifierLabel != l.identifierLabel {
+			g = s.newGroup(name, l.identifierLabel)
+		}
+
+		g.collector.ReplaceScrapers(l.scrapers)
+		groups[name] = g
+	}
+
+	return groups
+}
+
+// newGroup creates a group whose metrics are merged with the identifier label if it is not empty.
+func (s *server) newGroup(name string, identifierLabel model.LabelName) *group {
+	opts := []promaggr.CollectorOption{promaggr.Logger(s.logger.WithValues("group", name))}
+	if identifierLabel != "" {
+		opts = append(opts, promaggr.MergeOptions(promaggr.IdentifierLabel(identifierLabel)))
+	}
+
+	collector := promaggr.NewCollector(nil, opts...)
+
+	return &group{
+		collector: collector,
+		handler: promhttp.HandlerFor(collector, promhttp.HandlerOpts{
+			ErrorLog:      errorLogger{s.logger},
+			ErrorHandling: promhttp.ContinueOnError,
+		}),
+		identifierLabel: identifierLabel,
+	}
+}
+
+// targetURLs returns the URLs of the Scrapers of the collector and the groups.
+// The caller must hold the lock of the mutex.
+func (s *server) targetURLs() map[string]struct{} {
+	urls := make(map[string]struct{})
+
+	collectors := []*promaggr.Collector{s.collector}
+	for _, g := range s.groups {
+		collectors = append(collectors, g.collector)
+	}
+
+	for _, collector := range collectors {
+		for _, scraper := range collector.ScraperList() {
+			urls[scraper.URL] = struct{}{}
+		}
+	}
+
+	return urls
+}
+
+func (s *server) load() (*loaded, error) {
+	cfg, err := LoadFile(s.configFile)
+	if err != nil {
+		return nil, err
+	}
+
+	scrapers, err := cfg.Scrapers(promaggr.Metrics(s.metrics))
+	if err != nil {
+		return nil, err
+	}
+
+	groupScrapers, err := cfg.GroupScrapers(promaggr.Metrics(s.metrics))
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make(map[string]loadedGroup, len(groupScrapers))
+	for name, scrapers := range groupScrapers {
+		groups[name] = loadedGroup{scrapers: scrapers, identifierLabel: cfg.Groups[name].IdentifierLabel}
+	}
+
+	// The probe scrapers are not given the scrape metrics,
+	// as their targets are chosen by the requests and the series of them would grow without bound.
+	return &loaded{
+		scrapers: scrapers,
+		groups:   groups,
+		modules:  cfg.ProbeModules(),
+	}, nil
+}
+
+// handler returns the HTTP handler serving the aggregated metrics at the given path
+// along with the metrics of promaggr itself.
+// The metrics of each group are served under "<metrics path>/<name>" without the metrics of promaggr itself.
+// The status of the targets is served at /targets as HTML and at /api/v1/targets as JSON.
+func (s *server) handler(metricsPath string) http.Handler {
+	mux := http.NewServeMux()
+
+	// The collector is gathered first so that the scrape metrics reflect the current scrape.
+	metricsHandler := promhttp.HandlerFor(
+		prometheus.Gatherers{s.collector, s.registry},
+		promhttp.HandlerOpts{
+			ErrorLog:      errorLogger{s.logger},
+			ErrorHandling: promhttp.ContinueOnError,
+		},
+	)
+
+	// The metrics path and the groups under it are served by one handler,
+	// as they share the pattern when the metrics path ends with a slash, e.g. "/".
+	prefix := strings.TrimSuffix(metricsPath, "/") + "/"
+	groupHandler := http.StripPrefix(prefix, http.HandlerFunc(s.handleGroup))
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == metricsPath {
+			metricsHandler.ServeHTTP(w, r)
+
+			return
+		}
+
+		groupHandler.ServeHTTP(w, r)
+	})
+
+	mux.Handle(metricsPath, handler)
+
+	if prefix != metricsPath {
+		mux.Handle(prefix, handler)
+	}
+
+	mux.HandleFunc("/-/healthy", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		_, _ = fmt.Fprintln(w, "OK")
+	})
+
+	mux.HandleFunc("/-/reload", s.handleReload)
+
+	mux.HandleFunc("/probe", s.handleProbe)
+
+	mux.HandleFunc("/targets", s.handleTargets(metricsPath))
+	mux.HandleFunc("/api/v1/targets", s.handleTargetsAPI(metricsPath))
+
+	return mux
+}
+
+// handleGroup serves the metrics of the group whose name is the path of the request.
+func (s *server) handleGroup(w http.ResponseWriter, r *http.Request) {
+	s.mutex.RLock()
+	g, ok := s.groups[r.URL.Path]
+	s.mutex.RUnlock()
+
+	if !ok {
+		http.NotFound(w, r)
+
+		return
+	}
+
+	g.handler.ServeHTTP(w, r)
+}
+
+// handleProbe scrapes the target given by the target and module parameters with the configured modules.
+func (s *server) handleProbe(w http.ResponseWriter, r *http.Request) {
+	s.mutex.RLock()
+	handler := s.probeHandler
+	s.mutex.RUnlock()
+
+	handler.ServeHTTP(w, r)
+}
+
+func (s *server) handleReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Only POST requests allowed", http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	if err := s.reload(); err != nil {
+		s.logger.Error(err, "failed to reload config", "file", s.configFile)
+		http.Error(w, fmt.Sprintf("failed to reload config: %s", err), http.StatusInternalServerError)
+
+		return
+	}
+
+	s.logger.Info("reloaded config", "file", s.configFile)
+	w.WriteHeader(http.StatusOK)
+}
+
+// errorLogger adapts logr.Logger to promhttp.Logger.
+type errorLogger struct {
+	logger logr.Logger
+}
+
+func (l errorLogger) Println(v ...interface{}) {
+	l.logger.Error(nil, fmt.Sprint(v...))
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/common/expfmt"
+)
+
+const targetMetrics = `# HELP http_requests_total Dummy text.
+# TYPE http_requests_total counter
+http_requests_total{code="200"} 1
+`
+
+func TestServer(t *testing.T) {
+	t.Parallel()
+
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", string(expfmt.FmtText))
+		_, _ = io.WriteString(w, targetMetrics)
+	}))
+	defer target.Close()
+
+	configFile := writeConfig(t, filepath.Join(t.TempDir(), "promaggr.yml"), targetConfig(target.URL, "node"))
+
+	s, err := newServer(configFile, logr.Discard())
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+
+	assertMetrics(t, s.handler("/metrics"),
+		`http_requests_total{code="200",job="node"} 1`,
+		`promaggr_scrape_uncompressed_bytes_total{target="`+target.URL+`"}`,
+	)
+}
+
+func TestServerReload(t *testing.T) {
+	t.Parallel()
+
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", string(expfmt.FmtText))
+		_, _ = io.WriteString(w, targetMetrics)
+	}))
+	defer target.Close()
+
+	configFile := writeConfig(t, filepath.Join(t.TempDir(), "promaggr.yml"), targetConfig(target.URL, "node"))
+
+	s, err := newServer(configFile, logr.Discard())
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+
+	handler := s.handler("/metrics")
+
+	if rec := serve(handler, http.MethodGet, "/-/reload"); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("status code mismatch: want(%d) got(%d)", http.StatusMethodNotAllowed, rec.Code)
+	}
+
+	writeConfig(t, configFile, targetConfig(target.URL, "reloaded"))
+
+	if rec := serve(handler, http.MethodPost, "/-/reload"); rec.Code != http.StatusOK {
+		t.Errorf("status code mismatch: want(%d) got(%d)", http.StatusOK, rec.Code)
+	}
+
+	assertMetrics(t, handler,
+		`http_requests_total{code="200",job="reloaded"} 1`,
+		"promaggr_config_last_reload_successful 1",
+	)
+
+	writeConfig(t, configFile, "targets:\n  - labels:\n      job: invalid\n")
+
+	if rec := serve(handler, http.MethodPost, "/-/reload"); rec.Code != http.StatusInternalServerError {
+		t.Errorf("status code mismatch: want(%d) got(%d)", http.StatusInternalServerError, rec.Code)
+	}
+
+	assertMetrics(t, handler,
+		`http_requests_total{code="200",job="reloaded"} 1`,
+		"promaggr_config_last_reload_successful 0",
+	)
+
+	// The scrape metrics of a removed target are deleted on reload.
+	writeConfig(t, configFile, targetConfig(target.URL+"/metrics", "moved"))
+
+	if rec := serve(handler, http.MethodPost, "/-/reload"); rec.Code != http.StatusOK {
+		t.Errorf("status code mismatch: want(%d) got(%d)", http.StatusOK, rec.Code)
+	}
+
+	body := serve(handler, http.MethodGet, "/metrics").Body.String()
+	if notWant := `target="` + target.URL + `"}`; strings.Contains(body, notWant) {
+		t.Errorf("response contains %q of the removed target:\n%s", notWant, body)
+	}
+
+	if want := `target="` + target.URL + `/metrics"}`; !strings.Contains(body, want) {
+		t.Errorf("response does not contain %q:\n%s", want, body)
+	}
+}
+
+func TestServerGroups(t *testing.T) {
+	t.Parallel()
+
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", string(expfmt.FmtText))
+		_, _ = io.WriteString(w, targetMetrics)
+	}))
+	defer target.Close()
+
+	groupConfig := func(name, job string) string {
+		return "groups:\n  " + name + ":\n    targets:\n      - url: " + target.URL + "\n        labels:\n          job: " + job + "\n"
+	}
+
+	configFile := writeConfig(t, filepath.Join(t.TempDir(), "promaggr.yml"),
+		targetConfig(target.URL, "node")+groupConfig("cluster-a", "cluster-a"))
+
+	s, err := newServer(configFile, logr.Discard())
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+
+	handler := s.handler("/metrics")
+
+	rec := serve(handler, http.MethodGet, "/metrics/cluster-a")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status code mismatch: want(%d) got(%d)", http.StatusOK, rec.Code)
+	}
+
+	body := rec.Body.String()
+	if want := `http_requests_total{code="200",job="cluster-a"} 1`; !strings.Contains(body, want) {
+		t.Errorf("response does not contain %q:\n%s", want, body)
+	}
+
+	if notWant := `job="node"`; strings.Contains(body, notWant) {
+		t.Errorf("response contains %q of another group:\n%s", notWant, body)
+	}
+
+	// The groups are replaced on reload.
+	writeConfig(t, configFile, groupConfig("gpu-nodes", "gpu"))
+
+	if rec := serve(handler, http.MethodPost, "/-/reload"); rec.Code != http.StatusOK {
+		t.Fatalf("status code mismatch: want(%d) got(%d)", http.StatusOK, rec.Code)
+	}
+
+	if rec := serve(handler, http.MethodGet, "/metrics/cluster-a"); rec.Code != http.StatusNotFound {
+		t.Errorf("status code mismatch: want(%d) got(%d)", http.StatusNotFound, rec.Code)
+	}
+
+	if rec := serve(handler, http.MethodGet, "/metrics/gpu-nodes"); rec.Code != http.StatusOK {
+		t.Errorf("status code mismatch: want(%d) got(%d)", http.StatusOK, rec.Code)
+	}
+}
+
+func TestServerGroupsMetricsPath(t *testing.T) {
+	t.Parallel()
+
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", string(expfmt.FmtText))
+		_, _ = io.WriteString(w, targetMetrics)
+	}))
+	defer target.Close()
+
+	configFile := writeConfig(t, filepath.Join(t.TempDir(), "promaggr.yml"),
+		targetConfig(target.URL, "node")+"groups:\n  gpu-nodes:\n    targets:\n      - url: "+target.URL+"\n")
+
+	s, err := newServer(configFile, logr.Discard())
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+
+	tests := []struct {
+		metricsPath string
+		groupPath   string
+	}{
+		{metricsPath: "/metrics", groupPath: "/metrics/gpu-nodes"},
+		{metricsPath: "/metrics/", groupPath: "/metrics/gpu-nodes"},
+		{metricsPath: "/", groupPath: "/gpu-nodes"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.metricsPath, func(t *testing.T) {
+			t.Parallel()
+
+			handler := s.handler(tt.metricsPath)
+
+			if rec := serve(handler, http.MethodGet, tt.metricsPath); rec.Code != http.StatusOK {
+				t.Errorf("status code of %s mismatch: want(%d) got(%d)", tt.metricsPath, http.StatusOK, rec.Code)
+			}
+
+			if rec := serve(handler, http.MethodGet, tt.groupPath); rec.Code != http.StatusOK {
+				t.Errorf("status code of %s mismatch: want(%d) got(%d)", tt.groupPath, http.StatusOK, rec.Code)
+			}
+
+			if rec := serve(handler, http.MethodGet, tt.groupPath+"-unknown"); rec.Code != http.StatusNotFound {
+				t.Errorf("status code of %s mismatch: want(%d) got(%d)", tt.groupPath+"-unknown", http.StatusNotFound, rec.Code)
+			}
+
+			if rec := serve(handler, http.MethodGet, "/-/healthy"); rec.Code != http.StatusOK {
+				t.Errorf("status code of /-/healthy mismatch: want(%d) got(%d)", http.StatusOK, rec.Code)
+			}
+		})
+	}
+}
+
+func TestServerProbe(t *testing.T) {
+	t.Parallel()
+
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", string(expfmt.FmtText))
+		_, _ = io.WriteString(w, targetMetrics)
+	}))
+	defer target.Close()
+
+	configFile := writeConfig(t, filepath.Join(t.TempDir(), "promaggr.yml"),
+		"modules:\n  node:\n    labels:\n      job: node\n")
+
+	s, err := newServer(configFile, logr.Discard())
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+
+	handler := s.handler("/metrics")
+	address := strings.TrimPrefix(target.URL, "http://")
+
+	rec := serve(handler, http.MethodGet, "/probe?module=node&target="+address)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status code mismatch: want(%d) got(%d)", http.StatusOK, rec.Code)
+	}
+
+	if want := `http_requests_total{code="200",job="node"} 1`; !strings.Contains(rec.Body.String(), want) {
+		t.Errorf("response does not contain %q:\n%s", want, rec.Body.String())
+	}
+
+	if rec := serve(handler, http.MethodGet, "/probe?module=unknown&target="+address); rec.Code != http.StatusBadRequest {
+		t.Errorf("status code mismatch: want(%d) got(%d)", http.StatusBadRequest, rec.Code)
+	}
+
+	// The probed targets have no scrape metrics, as they are chosen by the requests.
+	body := serve(handler, http.MethodGet, "/metrics").Body.String()
+	if notWant := `target="` + target.URL + `/metrics"}`; strings.Contains(body, notWant) {
+		t.Errorf("response contains %q of the probed target:\n%s", notWant, body)
+	}
+}
+
+func targetConfig(url, job string) string {
+	return "targets:\n  - url: " + url + "\n    labels:\n      job: " + job + "\n"
+}
+
+func writeConfig(t *testing.T, path, config string) string {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	return path
+}
+
+func serve(handler http.Handler, method, target string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(method, target, nil))
+
+	return rec
+}
+
+func assertMetrics(t *testing.T, handler http.Handler, wants ...string) {
+	t.Helper()
+
+	rec := serve(handler, http.MethodGet, "/metrics")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status code mismatch: want(%d) got(%d)", http.StatusOK, rec.Code)
+	}
+
+	body := rec.Body.String()
+
+	for _, want := range wants {
+		if !strings.Contains(body, want) {
+			t.Errorf("response does not contain %q:\n%s", want, body)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/d-kuro/promaggr"
+	"github.com/prometheus/common/model"
+)
+
+// targetGroupStatus is the status of the targets of a collector, served at its metrics path.
+type targetGroupStatus struct {
+	Path    string         `json:"path"`
+	Targets []targetStatus `json:"targets"`
+}
+
+// targetStatus is the status of a target in the JSON API.
+// The lastScrapeDuration is in seconds.
+type targetStatus struct {
+	Identifier         string                `json:"identifier"`
+	URL                string                `json:"url,omitempty"`
+	Labels             model.LabelSet        `json:"labels,omitempty"`
+	Health             promaggr.TargetHealth `json:"health"`
+	LastScrape         time.Time             `json:"lastScrape"`
+	LastScrapeDuration float64               `json:"lastScrapeDuration"`
+	Samples            int                   `json:"samples"`
+	LastError          string                `json:"lastError"`
+}
+
+var targetsTemplate = template.Must(template.New("targets").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>promaggr targets</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; vertical-align: top; }
+.up { color: #2a7a2a; }
+.down { color: #b22222; }
+.unknown { color: #888; }
+</style>
+</head>
+<body>
+<h1>Targets</h1>
+{{range .}}
+<h2>{{.Path}}</h2>
+<table>
+<tr><th>Endpoint</th><th>Labels</th><th>Health</th><th>Last Scrape</th><th>Duration</th><th>Samples</th><th>Error</th></tr>
+{{range .Targets}}
+<tr>
+<td>{{if .URL}}{{.URL}}{{else}}{{.Identifier}}{{end}}</td>
+<td>{{range $name, $value := .Labels}}{{$name}}="{{$value}}" {{end}}</td>
+<td class="{{.Health}}">{{.Health}}</td>
+<td>{{if not .LastScrape.IsZero}}{{.LastScrape.Format "2006-01-02T15:04:05.000Z07:00"}}{{end}}</td>
+<td>{{if not .LastScrape.IsZero}}{{printf "%.3fs" .LastScrapeDuration}}{{end}}</td>
+<td>{{.Samples}}</td>
+<td>{{.LastError}}</td>
+</tr>
+{{end}}
+</table>
+{{end}}
+</body>
+</html>
+`))
+
+// targets returns the status of the targets of the collector served at the metrics path and of each group.
+func (s *server) targets(metricsPath string) []targetGroupStatus {
+	statuses := []targetGroupStatus{newTargetGroupStatus(metricsPath, s.collector)}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	names := make([]string, 0, len(s.groups))
+	for name := range s.groups {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	prefix := strings.TrimSuffix(metricsPath, "/") + "/"
+	for _, name := range names {
+		statuses = append(statuses, newTargetGroupStatus(prefix+name, s.groups[name].collector))
+	}
+
+	return statuses
+}
+
+func newTargetGroupStatus(path string, collector *promaggr.Collector) targetGroupStatus {
+	targets := collector.Targets()
+	status := targetGroupStatus{
+		Path:    path,
+		Targets: make([]targetStatus, 0, len(targets)),
+	}
+
+	for _, target := range targets {
+		t := targetStatus{
+			Identifier:         target.Identifier,
+			URL:                target.URL,
+			Labels:             target.Labels,
+			Health:             target.Health,
+			LastScrape:         target.LastScrape,
+			LastScrapeDuration: target.LastScrapeDuration.Seconds(),
+			Samples:            target.Samples,
+		}
+
+		if target.LastError != nil {
+			t.LastError = target.LastError.Error()
+		}
+
+		status.Targets = append(status.Targets, t)
+	}
+
+	return status
+}
+
+// handleTargets serves the status of the targets as an HTML page.
+func (s *server) handleTargets(metricsPath string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+		if err := targetsTemplate.Execute(w, s.targets(metricsPath)); err != nil {
+			s.logger.Error(err, "failed to render targets")
+		}
+	}
+}
+
+// handleTargetsAPI serves the status of the targets as JSON.
+func (s *server) handleTargetsAPI(metricsPath string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if err := json.NewEncoder(w).Encode(s.targets(metricsPath)); err != nil {
+			s.logger.Error(err, "failed to encode targets")
+		}
+	}
+}
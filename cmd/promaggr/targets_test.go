@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/common/expfmt"
+)
+
+func TestServerTargets(t *testing.T) {
+	t.Parallel()
+
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", string(expfmt.FmtText))
+		_, _ = io.WriteString(w, targetMetrics)
+	}))
+	defer target.Close()
+
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	configFile := writeConfig(t, filepath.Join(t.TempDir(), "promaggr.yml"),
+		targetConfig(target.URL, "node")+"groups:\n  cluster-a:\n    targets:\n      - url: "+down.URL+"\n")
+
+	s, err := newServer(configFile, logr.Discard())
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+
+	handler := s.handler("/metrics")
+
+	// The targets are scraped when the metrics are served.
+	serve(handler, http.MethodGet, "/metrics")
+	serve(handler, http.MethodGet, "/metrics/cluster-a")
+
+	rec := serve(handler, http.MethodGet, "/api/v1/targets")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status code mismatch: want(%d) got(%d)", http.StatusOK, rec.Code)
+	}
+
+	var groups []targetGroupStatus
+	if err := json.Unmarshal(rec.Body.Bytes(), &groups); err != nil {
+		t.Fatalf("failed to decode targets: %v", err)
+	}
+
+	if len(groups) != 2 {
+		t.Fatalf("number of groups mismatch: want(2) got(%d)", len(groups))
+	}
+
+	if got := groups[0]; got.Path != "/metrics" || len(got.Targets) != 1 ||
+		got.Targets[0].Health != "up" || got.Targets[0].Samples != 1 || got.Targets[0].Labels["job"] != "node" {
+		t.Errorf("unexpected status of the default group: %+v", got)
+	}
+
+	if got := groups[1]; got.Path != "/metrics/cluster-a" || len(got.Targets) != 1 ||
+		got.Targets[0].Health != "down" || !strings.Contains(got.Targets[0].LastError, "unexpected status code: 503") {
+		t.Errorf("unexpected status of the group: %+v", got)
+	}
+
+	rec = serve(handler, http.MethodGet, "/targets")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status code mismatch: want(%d) got(%d)", http.StatusOK, rec.Code)
+	}
+
+	for _, want := range []string{target.URL, `<td class="down">down</td>`, "/metrics/cluster-a"} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("response does not contain %q:\n%s", want, rec.Body.String())
+		}
+	}
+}
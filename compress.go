@@ -0,0 +1,67 @@
+package promaggr
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// acceptEncodingHeader is the Accept-Encoding header sent by the Scraper.
+const acceptEncodingHeader = "zstd, gzip"
+
+// ErrUnsupportedContentEncoding is returned when the scraping target responds with an unsupported Content-Encoding.
+var ErrUnsupportedContentEncoding = errors.New("unsupported content encoding")
+
+// decompress returns a reader that decompresses r according to the given Content-Encoding.
+// If the encoding is empty or "identity", r is returned as it is.
+func decompress(r io.Reader, encoding string) (io.ReadCloser, error) {
+	switch encoding {
+	case "", "identity":
+		return io.NopCloser(r), nil
+	case "gzip":
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create gzip reader: %w", err)
+		}
+
+		return gr, nil
+	case "zstd":
+		zr, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create zstd reader: %w", err)
+		}
+
+		return zstdReadCloser{zr}, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedContentEncoding, encoding)
+	}
+}
+
+// zstdReadCloser adapts zstd.Decoder to the io.ReadCloser interface.
+type zstdReadCloser struct {
+	*zstd.Decoder
+}
+
+// Close implements the io.Closer interface.
+func (r zstdReadCloser) Close() error {
+	r.Decoder.Close()
+
+	return nil
+}
+
+// countingReader counts the number of bytes read from r.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+// Read implements the io.Reader interface.
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+
+	return n, err
+}
@@ -0,0 +1,130 @@
+package promaggr_test
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/d-kuro/promaggr"
+	"github.com/klauspost/compress/zstd"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+)
+
+func TestScraperCompression(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		encoding string
+		writer   func(w io.Writer) (io.WriteCloser, error)
+	}{
+		{
+			name:     "gzip",
+			encoding: "gzip",
+			writer: func(w io.Writer) (io.WriteCloser, error) {
+				return gzip.NewWriter(w), nil
+			},
+		},
+		{
+			name:     "zstd",
+			encoding: "zstd",
+			writer: func(w io.Writer) (io.WriteCloser, error) {
+				return zstd.NewWriter(w)
+			},
+		},
+		{
+			name:     "identity",
+			encoding: "",
+			writer: func(w io.Writer) (io.WriteCloser, error) {
+				return nopWriteCloser{w}, nil
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			scrapeTarget := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") || !strings.Contains(r.Header.Get("Accept-Encoding"), "zstd") {
+					t.Errorf("unexpected Accept-Encoding header: %s", r.Header.Get("Accept-Encoding"))
+				}
+
+				w.Header().Set("Content-Type", string(expfmt.FmtText))
+
+				if tt.encoding != "" {
+					w.Header().Set("Content-Encoding", tt.encoding)
+				}
+
+				cw, err := tt.writer(w)
+				if err != nil {
+					t.Errorf("failed to create writer: %v", err)
+
+					return
+				}
+
+				_, _ = io.WriteString(cw, metricsText)
+				_ = cw.Close()
+			}))
+			defer scrapeTarget.Close()
+
+			metrics := promaggr.NewScrapeMetrics()
+			scraper := promaggr.NewScraper(scrapeTarget.URL, promaggr.Metrics(metrics))
+
+			mfs, err := scraper.Scrape(context.Background())
+			if err != nil {
+				t.Fatalf("failed to scrape: %v", err)
+			}
+
+			if len(mfs) != 4 {
+				t.Errorf("mismatch in the number of metric families: want(%d) got(%d)", 4, len(mfs))
+			}
+
+			uncompressed := gatherCounterValue(t, metrics, "promaggr_scrape_uncompressed_bytes_total")
+			if uncompressed != float64(len(metricsText)) {
+				t.Errorf("mismatch in the uncompressed bytes: want(%d) got(%v)", len(metricsText), uncompressed)
+			}
+
+			compressed := gatherCounterValue(t, metrics, "promaggr_scrape_compressed_bytes_total")
+			if tt.encoding != "" && compressed >= uncompressed {
+				t.Errorf("compressed bytes are not less than uncompressed bytes: compressed(%v) uncompressed(%v)", compressed, uncompressed)
+			}
+		})
+	}
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+func gatherCounterValue(t *testing.T, c prometheus.Collector, name string) float64 {
+	t.Helper()
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(c)
+
+	mfs, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+
+	for _, mf := range mfs {
+		if mf.GetName() == name && len(mf.GetMetric()) == 1 {
+			return mf.GetMetric()[0].GetCounter().GetValue()
+		}
+	}
+
+	t.Fatalf("metric not found: %s", name)
+
+	return 0
+}
@@ -2,6 +2,7 @@ package promaggr
 
 import (
 	"sort"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	dto "github.com/prometheus/client_model/go"
@@ -57,19 +58,34 @@ func (s labelSet) toLabelValueSlice() []string {
 }
 
 // MetricFamilyToDesc generates prometheus.Desc from MetricFamily.
+// The label names of the prometheus.Desc are the ones of the first metric,
+// while the other metrics of the MetricFamily may have different label names, e.g. the federated metrics.
 func MetricFamilyToDesc(f *dto.MetricFamily) *prometheus.Desc {
-	labelSet := newLabelSet(f.Metric[0].GetLabel())
+	return metricToDesc(f, f.Metric[0])
+}
+
+// metricToDesc generates prometheus.Desc from the metric of the MetricFamily with its own label names.
+func metricToDesc(f *dto.MetricFamily, metric *dto.Metric) *prometheus.Desc {
+	labelSet := newLabelSet(metric.GetLabel())
 
 	return prometheus.NewDesc(f.GetName(), f.GetHelp(), labelSet.toLabelNameSlice(), nil)
 }
 
 // MetricFamilyToMetrics generates slice of prometheus.Metric slice from MetricFamily.
+// The metrics of the MetricFamily may have different label names.
 func MetricFamilyToMetrics(f *dto.MetricFamily) []prometheus.Metric {
 	metrics := make([]prometheus.Metric, 0, len(f.Metric))
 
 	for _, metric := range f.GetMetric() {
-		desc := MetricFamilyToDesc(f)
-		metrics = append(metrics, convertMetric(f.GetType(), desc, metric))
+		desc := metricToDesc(f, metric)
+		m := convertMetric(f.GetType(), desc, metric)
+
+		// Keep the timestamp if the metric has one, e.g. metrics federated with HonorTimestamps.
+		if metric.TimestampMs != nil {
+			m = prometheus.NewMetricWithTimestamp(time.Unix(0, metric.GetTimestampMs()*int64(time.Millisecond)), m)
+		}
+
+		metrics = append(metrics, m)
 	}
 
 	return metrics
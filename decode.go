@@ -0,0 +1,244 @@
+package promaggr
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// ErrBodySizeLimitExceeded is returned when the size of the response body exceeds the BodySizeLimit.
+var ErrBodySizeLimitExceeded = errors.New("body size limit exceeded")
+
+// NewDecoder returns a new expfmt.Decoder that decodes MetricFamily's in the given format from r.
+// Unlike expfmt.NewDecoder, the text format is decoded family by family
+// instead of reading and parsing the whole input at once.
+// If the format is not the protobuf format, the text format is assumed.
+func NewDecoder(r io.Reader, format expfmt.Format) expfmt.Decoder {
+	if format == expfmt.FmtProtoDelim {
+		return expfmt.NewDecoder(r, format)
+	}
+
+	return &textDecoder{r: bufio.NewReader(r)}
+}
+
+// decodeMetricFamilies decodes all MetricFamily's from the decoder.
+// The given function is called for each MetricFamily as it is decoded, and decoding stops if it returns an error.
+// MetricFamily's with the same name are merged into one.
+func decodeMetricFamilies(decoder expfmt.Decoder, fn func(mf *dto.MetricFamily) error) ([]*dto.MetricFamily, error) {
+	mfs := make([]*dto.MetricFamily, 0)
+	mfSet := make(map[string]*dto.MetricFamily)
+
+	for {
+		mf := &dto.MetricFamily{}
+		if err := decoder.Decode(mf); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+
+			return nil, fmt.Errorf("failed to decode metric family: %w", err)
+		}
+
+		if err := fn(mf); err != nil {
+			return nil, err
+		}
+
+		if existing, ok := mfSet[mf.GetName()]; ok {
+			existing.Metric = append(existing.Metric, mf.GetMetric()...)
+
+			continue
+		}
+
+		mfSet[mf.GetName()] = mf
+		mfs = append(mfs, mf)
+	}
+
+	return mfs, nil
+}
+
+// textDecoder implements the expfmt.Decoder interface for the text format.
+// It splits the input into the lines of each metric family and parses them one by one,
+// so that only one metric family is kept in memory at a time.
+type textDecoder struct {
+	r      *bufio.Reader
+	parser expfmt.TextParser
+
+	// next is the line that has been read ahead and belongs to the next metric family.
+	next []byte
+
+	// nextErr is the error returned when the next line was read.
+	nextErr error
+
+	// pending is the parsed MetricFamily's that have not been returned yet.
+	pending []*dto.MetricFamily
+}
+
+// Decode implements the expfmt.Decoder interface.
+func (d *textDecoder) Decode(v *dto.MetricFamily) error {
+	for len(d.pending) == 0 {
+		lines, readErr := d.readFamily()
+		if readErr != nil && !errors.Is(readErr, io.EOF) {
+			return readErr
+		}
+
+		if len(lines) > 0 {
+			parsed, err := d.parser.TextToMetricFamilies(bytes.NewReader(lines))
+			if err != nil {
+				return fmt.Errorf("failed to parse metric family: %w", err)
+			}
+
+			for _, mf := range parsed {
+				d.pending = append(d.pending, mf)
+			}
+
+			sort.Slice(d.pending, func(i, j int) bool {
+				return d.pending[i].GetName() < d.pending[j].GetName()
+			})
+		}
+
+		if readErr != nil && len(d.pending) == 0 {
+			return io.EOF
+		}
+	}
+
+	mf := d.pending[0]
+	d.pending = d.pending[1:]
+
+	v.Reset()
+	v.Name = mf.Name
+	v.Help = mf.Help
+	v.Type = mf.Type
+	v.Metric = mf.Metric
+
+	return nil
+}
+
+// readFamily reads and returns the lines that belong to the next metric family.
+// The error is io.EOF if the end of the input has been reached.
+func (d *textDecoder) readFamily() ([]byte, error) {
+	var (
+		buf        bytes.Buffer
+		familyName string
+		familyType string
+	)
+
+	for {
+		line, err := d.next, d.nextErr
+		d.next, d.nextErr = nil, nil
+
+		if line == nil && err == nil {
+			line, err = d.r.ReadBytes('\n')
+		}
+
+		if len(line) > 0 {
+			name, typ, isSample := parseLine(line)
+
+			if name != "" {
+				if familyName == "" {
+					familyName = name
+				} else if !belongsToFamily(familyName, familyType, name, isSample) {
+					d.next, d.nextErr = line, err
+
+					return buf.Bytes(), nil
+				}
+
+				if typ != "" {
+					familyType = typ
+				}
+			}
+
+			buf.Write(line)
+		}
+
+		if err != nil {
+			return buf.Bytes(), err
+		}
+	}
+}
+
+// parseLine returns the metric name of a line in the text format.
+// For HELP and TYPE lines, the metric name in the line is returned, along with the metric type for TYPE lines.
+// For sample lines, the name of the sample is returned and isSample will be true.
+// For blank lines and other comments, an empty name is returned.
+func parseLine(line []byte) (name, typ string, isSample bool) {
+	s := strings.TrimLeft(string(line), " \t")
+
+	if s == "" || s[0] == '\n' {
+		return "", "", false
+	}
+
+	if s[0] == '#' {
+		fields := strings.Fields(s[1:])
+		if len(fields) < 2 || (fields[0] != "HELP" && fields[0] != "TYPE") {
+			return "", "", false
+		}
+
+		if fields[0] == "TYPE" && len(fields) >= 3 {
+			return fields[1], strings.ToLower(fields[2]), false
+		}
+
+		return fields[1], "", false
+	}
+
+	if i := strings.IndexAny(s, "{ \t\n"); i >= 0 {
+		s = s[:i]
+	}
+
+	return s, "", true
+}
+
+// belongsToFamily reports whether a line with the given name belongs to the metric family.
+// The _sum, _count and _bucket samples belong to the summary and histogram families.
+func belongsToFamily(familyName, familyType, name string, isSample bool) bool {
+	if name == familyName {
+		return true
+	}
+
+	if !isSample {
+		return false
+	}
+
+	switch familyType {
+	case "summary":
+		return name == familyName+"_sum" || name == familyName+"_count"
+	case "histogram":
+		return name == familyName+"_sum" || name == familyName+"_count" || name == familyName+"_bucket"
+	default:
+		return false
+	}
+}
+
+// sizeLimitedReader reads from r, but returns ErrBodySizeLimitExceeded
+// if more than the remaining bytes are available.
+type sizeLimitedReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+// Read implements the io.Reader interface.
+func (r *sizeLimitedReader) Read(p []byte) (int, error) {
+	if r.remaining <= 0 {
+		// Check whether the input ends exactly at the limit.
+		var b [1]byte
+		if n, _ := io.ReadFull(r.r, b[:]); n > 0 {
+			return 0, ErrBodySizeLimitExceeded
+		}
+
+		return 0, io.EOF
+	}
+
+	if int64(len(p)) > r.remaining {
+		p = p[:r.remaining]
+	}
+
+	n, err := r.r.Read(p)
+	r.remaining -= int64(n)
+
+	return n, err
+}
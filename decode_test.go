@@ -0,0 +1,98 @@
+package promaggr_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/d-kuro/promaggr"
+	"github.com/google/go-cmp/cmp"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+func TestNewDecoder(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		text      string
+		wantNames []string
+	}{
+		{
+			name:      "all metric types",
+			text:      metricsText,
+			wantNames: []string{"dummy_counter_metric", "dummy_gauge_metric", "dummy_histogram_metric", "dummy_summary_metric"},
+		},
+		{
+			name: "untyped metrics with suffixes",
+			text: `# comment
+untyped_metric{name="foo"} 1
+untyped_metric{name="bar"} 2
+
+untyped_metric_sum 3
+untyped_metric_count 4
+`,
+			wantNames: []string{"untyped_metric", "untyped_metric_sum", "untyped_metric_count"},
+		},
+		{
+			name:      "empty",
+			text:      "",
+			wantNames: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			decoder := promaggr.NewDecoder(strings.NewReader(tt.text), expfmt.FmtText)
+			gotNames := make([]string, 0)
+			out := bytes.Buffer{}
+
+			for {
+				mf := &dto.MetricFamily{}
+				if err := decoder.Decode(mf); err != nil {
+					if errors.Is(err, io.EOF) {
+						break
+					}
+
+					t.Fatalf("failed to decode: %v", err)
+				}
+
+				gotNames = append(gotNames, mf.GetName())
+
+				if _, err := expfmt.MetricFamilyToText(&out, mf); err != nil {
+					t.Fatalf("failed to convert MetricFamily to text: %v", err)
+				}
+			}
+
+			if diff := cmp.Diff(tt.wantNames, gotNames); diff != "" {
+				t.Errorf("decoded metric families mismatch (-want +got):\n%s", diff)
+			}
+
+			// The decoded families must be the same as the ones parsed by expfmt.TextParser.
+			var parser expfmt.TextParser
+
+			parsed, err := parser.TextToMetricFamilies(strings.NewReader(tt.text))
+			if err != nil {
+				t.Fatalf("failed to parse prometheus metrics: %v", err)
+			}
+
+			want := bytes.Buffer{}
+
+			for _, name := range tt.wantNames {
+				if _, err := expfmt.MetricFamilyToText(&want, parsed[name]); err != nil {
+					t.Fatalf("failed to convert MetricFamily to text: %v", err)
+				}
+			}
+
+			if diff := cmp.Diff(want.String(), out.String()); diff != "" {
+				t.Errorf("decoded metrics mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
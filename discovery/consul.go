@@ -0,0 +1,362 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/common/model"
+)
+
+const (
+	// ConsulAddressLabel is the meta label holding the address of the node of the service.
+	ConsulAddressLabel model.LabelName = consulMetaLabelPrefix + "address"
+
+	// ConsulNodeLabel is the meta label holding the name of the node of the service.
+	ConsulNodeLabel model.LabelName = consulMetaLabelPrefix + "node"
+
+	// ConsulDatacenterLabel is the meta label holding the datacenter of the node.
+	ConsulDatacenterLabel model.LabelName = consulMetaLabelPrefix + "dc"
+
+	// ConsulServiceLabel is the meta label holding the name of the service.
+	ConsulServiceLabel model.LabelName = consulMetaLabelPrefix + "service"
+
+	// ConsulServiceIDLabel is the meta label holding the ID of the service instance.
+	ConsulServiceIDLabel model.LabelName = consulMetaLabelPrefix + "service_id"
+
+	// ConsulServiceAddressLabel is the meta label holding the address of the service instance.
+	ConsulServiceAddressLabel model.LabelName = consulMetaLabelPrefix + "service_address"
+
+	// ConsulServicePortLabel is the meta label holding the port of the service instance.
+	ConsulServicePortLabel model.LabelName = consulMetaLabelPrefix + "service_port"
+
+	// ConsulTagsLabel is the meta label holding the tags of the service instance
+	// joined by commas, with a leading and a trailing comma, e.g. ",primary,v1,".
+	ConsulTagsLabel model.LabelName = consulMetaLabelPrefix + "tags"
+
+	// ConsulNodeMetaLabelPrefix is the prefix of the meta labels holding the metadata of the node.
+	ConsulNodeMetaLabelPrefix = consulMetaLabelPrefix + "metadata_"
+
+	// ConsulServiceMetaLabelPrefix is the prefix of the meta labels holding the metadata of the service instance.
+	ConsulServiceMetaLabelPrefix = consulMetaLabelPrefix + "service_metadata_"
+
+	consulMetaLabelPrefix = MetaLabelPrefix + "consul_"
+
+	consulTokenHeader = "X-Consul-Token"
+
+	defaultConsulRefreshInterval = 30 * time.Second
+)
+
+var _ Discoverer = &ConsulDiscoverer{}
+
+// ConsulDiscovererOption is a functional option used by the NewConsulDiscoverer.
+type ConsulDiscovererOption func(*ConsulDiscoverer)
+
+// ConsulDiscoverer finds the instances of the services registered in the catalog of Consul at an interval.
+// Each service is a target group, and the meta labels prefixed with "__meta_consul_" are added to each target.
+type ConsulDiscoverer struct {
+	// Server is the URL of the Consul HTTP API, e.g. "http://localhost:8500".
+	Server string
+
+	// Services is a list of names of the services to discover.
+	// If not specified, all services will be used.
+	Services []string
+
+	// Tags is a list of tags which the service instances must have.
+	// If not specified, the instances are not filtered by tags.
+	Tags []string
+
+	// Datacenter is the datacenter to discover the services in.
+	// If not specified, the datacenter of the Consul agent will be used.
+	Datacenter string
+
+	// Token is the ACL token sent to Consul.
+	// If not specified, no token will be sent.
+	Token string
+
+	// RefreshInterval is the interval to fetch the catalog.
+	// If not specified, 30 seconds will be used.
+	RefreshInterval time.Duration
+
+	// HTTPClient is the http.Client to be used for the request. If not specified, the http.DefaultClient will be used.
+	HTTPClient *http.Client
+
+	// Logger is a logger that implements the logr.Logger interface.
+	// If it is not specified, nothing will be logged.
+	Logger logr.Logger
+}
+
+// NewConsulDiscoverer creates and returns a new ConsulDiscoverer which fetches the catalog from the server.
+func NewConsulDiscoverer(server string, opts ...ConsulDiscovererOption) *ConsulDiscoverer {
+	discoverer := &ConsulDiscoverer{
+		Server: server,
+	}
+
+	for _, o := range opts {
+		o(discoverer)
+	}
+
+	return discoverer
+}
+
+// ConsulServices is an option available for NewConsulDiscoverer.
+// Only the services of the given names are discovered.
+func ConsulServices(services ...string) ConsulDiscovererOption {
+	return func(d *ConsulDiscoverer) {
+		d.Services = services
+	}
+}
+
+// ConsulTags is an option available for NewConsulDiscoverer.
+// Only the service instances having all the given tags are discovered.
+func ConsulTags(tags ...string) ConsulDiscovererOption {
+	return func(d *ConsulDiscoverer) {
+		d.Tags = tags
+	}
+}
+
+// ConsulDatacenter is an option available for NewConsulDiscoverer.
+// The services are discovered in the given datacenter.
+func ConsulDatacenter(datacenter string) ConsulDiscovererOption {
+	return func(d *ConsulDiscoverer) {
+		d.Datacenter = datacenter
+	}
+}
+
+// ConsulToken is an option available for NewConsulDiscoverer.
+// The given ACL token is sent to Consul.
+func ConsulToken(token string) ConsulDiscovererOption {
+	return func(d *ConsulDiscoverer) {
+		d.Token = token
+	}
+}
+
+// ConsulRefreshInterval is an option available for NewConsulDiscoverer.
+// The catalog is fetched at the given interval.
+func ConsulRefreshInterval(interval time.Duration) ConsulDiscovererOption {
+	return func(d *ConsulDiscoverer) {
+		d.RefreshInterval = interval
+	}
+}
+
+// ConsulHTTPClient is an option available for NewConsulDiscoverer.
+// The catalog is fetched by the given client, e.g. to configure the TLS.
+func ConsulHTTPClient(client *http.Client) ConsulDiscovererOption {
+	return func(d *ConsulDiscoverer) {
+		d.HTTPClient = client
+	}
+}
+
+// ConsulLogger is an option available for NewConsulDiscoverer.
+// If a logger is set, the errors while fetching the catalog will be output to the log.
+func ConsulLogger(logger logr.Logger) ConsulDiscovererOption {
+	return func(d *ConsulDiscoverer) {
+		d.Logger = logger
+	}
+}
+
+// consulCatalogService is a service instance in the response of the /v1/catalog/service/:service endpoint.
+type consulCatalogService struct {
+	Node           string            `json:"Node"`
+	Address        string            `json:"Address"`
+	Datacenter     string            `json:"Datacenter"`
+	NodeMeta       map[string]string `json:"NodeMeta"`
+	ServiceID      string            `json:"ServiceID"`
+	ServiceName    string            `json:"ServiceName"`
+	ServiceAddress string            `json:"ServiceAddress"`
+	ServicePort    int               `json:"ServicePort"`
+	ServiceTags    []string          `json:"ServiceTags"`
+	ServiceMeta    map[string]string `json:"ServiceMeta"`
+}
+
+// Run implements the Discoverer interface.
+// If a service cannot be fetched, the target group previously fetched for it is kept.
+func (d *ConsulDiscoverer) Run(ctx context.Context, ch chan<- []*TargetGroup) {
+	interval := d.RefreshInterval
+	if interval <= 0 {
+		interval = defaultConsulRefreshInterval
+	}
+
+	groups := make(map[string]*TargetGroup)
+
+	runRefresh(ctx, interval, func(ctx context.Context) ([]*TargetGroup, bool) {
+		groups = d.refresh(ctx, groups)
+
+		return consulTargetGroups(groups), true
+	}, ch)
+}
+
+// refresh fetches the target groups of the services.
+// If the list of services cannot be fetched, the previous groups are returned as they are.
+func (d *ConsulDiscoverer) refresh(ctx context.Context, groups map[string]*TargetGroup) map[string]*TargetGroup {
+	services, err := d.services(ctx)
+	if err != nil {
+		d.logError(err, "failed to fetch consul services")
+
+		return groups
+	}
+
+	newGroups := make(map[string]*TargetGroup, len(services))
+
+	for _, service := range services {
+		group, err := d.service(ctx, service)
+		if err != nil {
+			d.logError(err, "failed to fetch consul service", "service", service)
+
+			if group, ok := groups[service]; ok {
+				newGroups[service] = group
+			}
+
+			continue
+		}
+
+		newGroups[service] = group
+	}
+
+	return newGroups
+}
+
+func (d *ConsulDiscoverer) logError(err error, msg string, keysAndValues ...interface{}) {
+	if d.Logger != nil {
+		d.Logger.Error(err, msg, append([]interface{}{"server", d.Server}, keysAndValues...)...)
+	}
+}
+
+// consulTargetGroups returns the target groups sorted by the name of the service.
+func consulTargetGroups(groups map[string]*TargetGroup) []*TargetGroup {
+	list := make([]*TargetGroup, 0, len(groups))
+	for _, group := range groups {
+		list = append(list, group)
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Source < list[j].Source })
+
+	return list
+}
+
+// services returns the names of the services to discover.
+func (d *ConsulDiscoverer) services(ctx context.Context) ([]string, error) {
+	if len(d.Services) > 0 {
+		return d.Services, nil
+	}
+
+	var services map[string][]string
+	if err := d.get(ctx, "/v1/catalog/services", &services); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(services))
+	for name := range services {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// service fetches the instances of the service into a target group.
+func (d *ConsulDiscoverer) service(ctx context.Context, name string) (*TargetGroup, error) {
+	var instances []consulCatalogService
+	if err := d.get(ctx, "/v1/catalog/service/"+url.PathEscape(name), &instances); err != nil {
+		return nil, err
+	}
+
+	group := &TargetGroup{
+		Labels: model.LabelSet{ConsulServiceLabel: model.LabelValue(name)},
+		Source: name,
+	}
+
+	for _, instance := range instances {
+		if !hasTags(instance.ServiceTags, d.Tags) {
+			continue
+		}
+
+		group.Targets = append(group.Targets, consulTarget(instance))
+	}
+
+	return group, nil
+}
+
+// get fetches the path of the Consul HTTP API and decodes the JSON response into v.
+func (d *ConsulDiscoverer) get(ctx context.Context, path string, v interface{}) error {
+	u, err := url.Parse(strings.TrimSuffix(d.Server, "/") + path)
+	if err != nil {
+		return fmt.Errorf("invalid consul server %s: %w", d.Server, err)
+	}
+
+	if d.Datacenter != "" {
+		u.RawQuery = url.Values{"dc": {d.Datacenter}}.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request to %s: %w", u, err)
+	}
+
+	if d.Token != "" {
+		req.Header.Set(consulTokenHeader, d.Token)
+	}
+
+	return getJSON(d.HTTPClient, req, v)
+}
+
+// hasTags reports whether the tags contain all the required tags.
+func hasTags(tags, required []string) bool {
+	for _, r := range required {
+		found := false
+
+		for _, tag := range tags {
+			if tag == r {
+				found = true
+
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// consulTarget returns the target of the service instance.
+// The address of the service instance is used if set, otherwise the address of the node is used.
+func consulTarget(instance consulCatalogService) model.LabelSet {
+	host := instance.ServiceAddress
+	if host == "" {
+		host = instance.Address
+	}
+
+	port := strconv.Itoa(instance.ServicePort)
+
+	target := model.LabelSet{
+		AddressLabel:              model.LabelValue(net.JoinHostPort(host, port)),
+		ConsulAddressLabel:        model.LabelValue(instance.Address),
+		ConsulNodeLabel:           model.LabelValue(instance.Node),
+		ConsulDatacenterLabel:     model.LabelValue(instance.Datacenter),
+		ConsulServiceIDLabel:      model.LabelValue(instance.ServiceID),
+		ConsulServiceAddressLabel: model.LabelValue(instance.ServiceAddress),
+		ConsulServicePortLabel:    model.LabelValue(port),
+		ConsulTagsLabel:           model.LabelValue("," + strings.Join(instance.ServiceTags, ",") + ","),
+	}
+
+	for key, value := range instance.NodeMeta {
+		target[model.LabelName(ConsulNodeMetaLabelPrefix+SanitizeLabelName(key))] = model.LabelValue(value)
+	}
+
+	for key, value := range instance.ServiceMeta {
+		target[model.LabelName(ConsulServiceMetaLabelPrefix+SanitizeLabelName(key))] = model.LabelValue(value)
+	}
+
+	return target
+}
@@ -0,0 +1,131 @@
+package discovery_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/d-kuro/promaggr/discovery"
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/common/model"
+)
+
+func TestConsulDiscoverer(t *testing.T) {
+	t.Parallel()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/catalog/services", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"consul": [], "node-exporter": ["metrics"]}`))
+	})
+	mux.HandleFunc("/v1/catalog/service/consul", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`[{"Node": "server1", "Address": "10.0.0.1", "Datacenter": "dc1", "ServiceID": "consul", "ServicePort": 8300}]`))
+	})
+	mux.HandleFunc("/v1/catalog/service/node-exporter", func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("X-Consul-Token"); got != "secret" {
+			http.Error(w, "permission denied", http.StatusForbidden)
+
+			return
+		}
+
+		if got := r.URL.Query().Get("dc"); got != "dc1" {
+			http.Error(w, "unknown datacenter", http.StatusInternalServerError)
+
+			return
+		}
+
+		_, _ = w.Write([]byte(`[
+			{
+				"Node": "node1", "Address": "10.0.0.1", "Datacenter": "dc1", "NodeMeta": {"rack": "a"},
+				"ServiceID": "node-exporter-1", "ServiceName": "node-exporter", "ServiceAddress": "10.0.1.1",
+				"ServicePort": 9100, "ServiceTags": ["metrics", "v1"], "ServiceMeta": {"version": "1.0"}
+			},
+			{
+				"Node": "node2", "Address": "10.0.0.2", "Datacenter": "dc1",
+				"ServiceID": "node-exporter-2", "ServiceName": "node-exporter",
+				"ServicePort": 9100, "ServiceTags": ["v1"]
+			}
+		]`))
+	})
+
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	discoverer := discovery.NewConsulDiscoverer(ts.URL,
+		discovery.ConsulServices("node-exporter"),
+		discovery.ConsulTags("metrics"),
+		discovery.ConsulDatacenter("dc1"),
+		discovery.ConsulToken("secret"),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch := make(chan []*discovery.TargetGroup)
+	go discoverer.Run(ctx, ch)
+
+	want := []*discovery.TargetGroup{
+		{
+			Targets: []model.LabelSet{
+				{
+					"__address__":                            "10.0.1.1:9100",
+					"__meta_consul_address":                  "10.0.0.1",
+					"__meta_consul_node":                     "node1",
+					"__meta_consul_dc":                       "dc1",
+					"__meta_consul_service_id":               "node-exporter-1",
+					"__meta_consul_service_address":          "10.0.1.1",
+					"__meta_consul_service_port":             "9100",
+					"__meta_consul_tags":                     ",metrics,v1,",
+					"__meta_consul_metadata_rack":            "a",
+					"__meta_consul_service_metadata_version": "1.0",
+				},
+			},
+			Labels: model.LabelSet{"__meta_consul_service": "node-exporter"},
+			Source: "node-exporter",
+		},
+	}
+
+	if diff := cmp.Diff(want, receive(t, ch)); diff != "" {
+		t.Errorf("target groups mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestConsulDiscovererAllServices(t *testing.T) {
+	t.Parallel()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/catalog/services", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"api": [], "web": []}`))
+	})
+	mux.HandleFunc("/v1/catalog/service/api", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`[{"Node": "node1", "Address": "10.0.0.1", "ServicePort": 8080}]`))
+	})
+	mux.HandleFunc("/v1/catalog/service/web", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+	})
+
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	discoverer := discovery.NewConsulDiscoverer(ts.URL)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch := make(chan []*discovery.TargetGroup)
+	go discoverer.Run(ctx, ch)
+
+	// The service which cannot be fetched is skipped.
+	groups := receive(t, ch)
+	if len(groups) != 1 {
+		t.Fatalf("number of target groups mismatch: want(1) got(%d)", len(groups))
+	}
+
+	if got := groups[0].Source; got != "api" {
+		t.Errorf("source mismatch: want(api) got(%s)", got)
+	}
+
+	if got := groups[0].Targets[0][discovery.AddressLabel]; got != "10.0.0.1:8080" {
+		t.Errorf("address mismatch: want(10.0.0.1:8080) got(%s)", got)
+	}
+}
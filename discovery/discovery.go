@@ -0,0 +1,359 @@
+// Package discovery keeps the Scrapers of a promaggr.Collector in sync with the targets found by service discovery.
+package discovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/d-kuro/promaggr"
+	"github.com/go-logr/logr"
+	"github.com/prometheus/common/model"
+)
+
+const (
+	// AddressLabel is the label holding the address of a target, e.g. "localhost:9100".
+	// It may also hold the URL of a target, in which case SchemeLabel and MetricsPathLabel are ignored.
+	AddressLabel model.LabelName = "__address__"
+
+	// SchemeLabel is the label holding the scheme used to scrape a target. The default is "http".
+	SchemeLabel model.LabelName = "__scheme__"
+
+	// MetricsPathLabel is the label holding the path used to scrape a target. The default is "/metrics".
+	MetricsPathLabel model.LabelName = "__metrics_path__"
+
+	// ParamLabelPrefix is the prefix of the labels holding the URL parameters used to scrape a target.
+	ParamLabelPrefix = "__param_"
+
+	// MetaLabelPrefix is the prefix of the labels added by the discoverers.
+	MetaLabelPrefix = "__meta_"
+
+	// InstanceLabel is the label identifying a target in the scraped metrics.
+	// If not set, it is set to the address of the target.
+	InstanceLabel model.LabelName = "instance"
+
+	// reservedLabelPrefix is the prefix of the labels that are not added to the scraped metrics.
+	reservedLabelPrefix = "__"
+
+	defaultScheme      = "http"
+	defaultMetricsPath = "/metrics"
+)
+
+// ErrMissingAddress is returned when a target has no AddressLabel.
+var ErrMissingAddress = errors.New("target has no address")
+
+var invalidLabelCharRE = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// TargetGroup is a set of targets sharing common labels.
+type TargetGroup struct {
+	// Targets is a list of targets identified by a label set.
+	// Each target has at least the AddressLabel.
+	Targets []model.LabelSet
+
+	// Labels is a set of labels shared by all targets in the group.
+	// The labels of a target take precedence over these.
+	Labels model.LabelSet
+
+	// Source is the identifier of the group, which is unique among the groups of a Discoverer.
+	Source string
+}
+
+// Discoverer finds targets to scrape metrics from.
+type Discoverer interface {
+	// Run sends all target groups found by the Discoverer to the channel whenever they change,
+	// until the context is done.
+	Run(ctx context.Context, ch chan<- []*TargetGroup)
+}
+
+// RelabelFunc rewrites the labels of a target before the Scraper is created.
+// If it returns an empty label set, the target is dropped.
+type RelabelFunc func(labels model.LabelSet) model.LabelSet
+
+// ManagerOption is a functional option used by the NewManager.
+type ManagerOption func(*Manager)
+
+// Manager keeps the Scrapers of a Collector in sync with the targets found by the Discoverers.
+// The Manager owns the Scrapers of the Collector,
+// so the Scrapers added by other means are removed by the Manager.
+type Manager struct {
+	// ScraperOptions are applied to every Scraper created by the Manager.
+	ScraperOptions []promaggr.ScraperOption
+
+	// Relabel rewrites the labels of each target.
+	// If not specified, the labels are used as they are.
+	Relabel RelabelFunc
+
+	// Logger is a logger that implements the logr.Logger interface.
+	// If it is not specified, nothing will be logged.
+	Logger logr.Logger
+
+	collector   *promaggr.Collector
+	discoverers map[string]Discoverer
+
+	mutex    sync.Mutex
+	groups   map[string][]*TargetGroup
+	scrapers map[string]*promaggr.Scraper
+}
+
+// NewManager creates and returns a new Manager
+// which keeps the Scrapers of the collector in sync with the targets found by the discoverers.
+// The key of the discoverers is the name of each Discoverer.
+func NewManager(collector *promaggr.Collector, discoverers map[string]Discoverer, opts ...ManagerOption) *Manager {
+	manager := &Manager{
+		collector:   collector,
+		discoverers: discoverers,
+		groups:      make(map[string][]*TargetGroup, len(discoverers)),
+		scrapers:    make(map[string]*promaggr.Scraper),
+	}
+
+	for _, o := range opts {
+		o(manager)
+	}
+
+	return manager
+}
+
+// ScraperOptions is an option available for NewManager.
+// The given options are applied to every Scraper created by the Manager,
+// e.g. to configure the authorization or the limits.
+func ScraperOptions(opts ...promaggr.ScraperOption) ManagerOption {
+	return func(m *Manager) {
+		m.ScraperOptions = append(m.ScraperOptions, opts...)
+	}
+}
+
+// Relabel is an option available for NewManager.
+// The labels of each target are rewritten by the given function.
+func Relabel(fn RelabelFunc) ManagerOption {
+	return func(m *Manager) {
+		m.Relabel = fn
+	}
+}
+
+// Logger is an option available for NewManager.
+// If a logger is set, the targets that cannot be scraped will be output to the log.
+func Logger(logger logr.Logger) ManagerOption {
+	return func(m *Manager) {
+		m.Logger = logger
+	}
+}
+
+type update struct {
+	name   string
+	groups []*TargetGroup
+}
+
+// Run runs the Discoverers and updates the Scrapers of the Collector whenever the targets change.
+// It blocks until the context is done.
+func (m *Manager) Run(ctx context.Context) {
+	updates := make(chan update)
+
+	var wg sync.WaitGroup
+
+	for name, discoverer := range m.discoverers {
+		name, discoverer := name, discoverer
+		ch := make(chan []*TargetGroup)
+
+		wg.Add(2)
+
+		go func() {
+			defer wg.Done()
+
+			discoverer.Run(ctx, ch)
+		}()
+
+		go func() {
+			defer wg.Done()
+
+			for {
+				select {
+				case groups := <-ch:
+					select {
+					case updates <- update{name: name, groups: groups}:
+					case <-ctx.Done():
+						return
+					}
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	for {
+		select {
+		case u := <-updates:
+			m.update(u.name, u.groups)
+		case <-ctx.Done():
+			wg.Wait()
+
+			return
+		}
+	}
+}
+
+// update replaces the target groups of the named Discoverer, and reconciles the Scrapers of the Collector.
+func (m *Manager) update(name string, groups []*TargetGroup) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.groups[name] = groups
+
+	names := make([]string, 0, len(m.groups))
+	for name := range m.groups {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	scrapers := make(map[string]*promaggr.Scraper, len(m.scrapers))
+	list := make([]*promaggr.Scraper, 0, len(m.scrapers))
+
+	for _, name := range names {
+		for _, group := range m.groups[name] {
+			for _, target := range group.Targets {
+				labels := group.Labels.Merge(target)
+				if m.Relabel != nil {
+					if labels = m.Relabel(labels); len(labels) == 0 {
+						continue
+					}
+				}
+
+				scraper, err := NewScraper(labels, m.ScraperOptions...)
+				if err != nil {
+					if m.Logger != nil {
+						m.Logger.Error(err, "failed to create scraper", "discoverer", name, "group", group.Source)
+					}
+
+					continue
+				}
+
+				id := scraper.ID()
+				if _, ok := scrapers[id]; ok {
+					continue
+				}
+
+				// The existing Scraper is kept to reuse its connections and the state of its secret files.
+				if existing, ok := m.scrapers[id]; ok {
+					scraper = existing
+				}
+
+				scrapers[id] = scraper
+				list = append(list, scraper)
+			}
+		}
+	}
+
+	m.scrapers = scrapers
+	m.collector.ReplaceScrapers(list)
+}
+
+// NewScraper creates a Scraper for the target identified by the labels.
+// The URL is built from the AddressLabel, SchemeLabel, MetricsPathLabel and the labels prefixed with ParamLabelPrefix.
+// The labels prefixed with "__" are not added to the scraped metrics,
+// and the InstanceLabel is set to the address if it is not set.
+func NewScraper(labels model.LabelSet, opts ...promaggr.ScraperOption) (*promaggr.Scraper, error) {
+	targetURL, err := TargetURL(labels)
+	if err != nil {
+		return nil, err
+	}
+
+	params := url.Values{}
+	targetLabels := make(model.LabelSet, len(labels))
+
+	for name, value := range labels {
+		switch {
+		case strings.HasPrefix(string(name), ParamLabelPrefix):
+			params.Set(strings.TrimPrefix(string(name), ParamLabelPrefix), string(value))
+		case strings.HasPrefix(string(name), reservedLabelPrefix):
+		default:
+			targetLabels[name] = value
+		}
+	}
+
+	if _, ok := targetLabels[InstanceLabel]; !ok {
+		targetLabels[InstanceLabel] = labels[AddressLabel]
+	}
+
+	opts = append(append([]promaggr.ScraperOption(nil), opts...), promaggr.Labels(targetLabels))
+	if len(params) > 0 {
+		opts = append(opts, promaggr.Params(params))
+	}
+
+	return promaggr.NewScraper(targetURL, opts...), nil
+}
+
+// TargetURL returns the URL to scrape the target identified by the labels.
+// If the AddressLabel holds a URL, it is used as it is.
+// Otherwise, the URL is built from the AddressLabel, SchemeLabel and MetricsPathLabel.
+func TargetURL(labels model.LabelSet) (string, error) {
+	address := string(labels[AddressLabel])
+	if address == "" {
+		return "", fmt.Errorf("%w: %s", ErrMissingAddress, labels)
+	}
+
+	if strings.Contains(address, "://") {
+		return address, nil
+	}
+
+	scheme := string(labels[SchemeLabel])
+	if scheme == "" {
+		scheme = defaultScheme
+	}
+
+	path := string(labels[MetricsPathLabel])
+	if path == "" {
+		path = defaultMetricsPath
+	}
+
+	return (&url.URL{Scheme: scheme, Host: address, Path: path}).String(), nil
+}
+
+var _ Discoverer = StaticDiscoverer(nil)
+
+// StaticDiscoverer is a Discoverer which finds the fixed target groups.
+type StaticDiscoverer []*TargetGroup
+
+// Run implements the Discoverer interface.
+// It sends the target groups once.
+func (d StaticDiscoverer) Run(ctx context.Context, ch chan<- []*TargetGroup) {
+	select {
+	case ch <- d:
+	case <-ctx.Done():
+	}
+}
+
+// SanitizeLabelName replaces the characters which are not allowed in a label name with underscores,
+// e.g. to add the metadata of a target as a meta label.
+func SanitizeLabelName(name string) string {
+	return invalidLabelCharRE.ReplaceAllString(name, "_")
+}
+
+// runRefresh calls the refresh at the interval and sends the target groups to the channel until the context is done.
+// The target groups are not sent if the refresh reports false, e.g. when they have not been changed.
+func runRefresh(ctx context.Context, interval time.Duration, refresh func(ctx context.Context) ([]*TargetGroup, bool), ch chan<- []*TargetGroup) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if groups, ok := refresh(ctx); ok {
+			select {
+			case ch <- groups:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
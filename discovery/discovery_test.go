@@ -0,0 +1,166 @@
+package discovery_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/d-kuro/promaggr"
+	"github.com/d-kuro/promaggr/discovery"
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/common/model"
+)
+
+func TestNewScraper(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		labels     model.LabelSet
+		wantURL    string
+		wantLabels model.LabelSet
+		wantParams url.Values
+		wantErr    error
+	}{
+		{
+			name:       "address",
+			labels:     model.LabelSet{"__address__": "localhost:9100", "job": "node"},
+			wantURL:    "http://localhost:9100/metrics",
+			wantLabels: model.LabelSet{"instance": "localhost:9100", "job": "node"},
+		},
+		{
+			name: "scheme, path and params",
+			labels: model.LabelSet{
+				"__address__":      "localhost:9115",
+				"__scheme__":       "https",
+				"__metrics_path__": "/probe",
+				"__param_module":   "http_2xx",
+				"__meta_filepath":  "targets.json",
+				"instance":         "blackbox",
+			},
+			wantURL:    "https://localhost:9115/probe",
+			wantLabels: model.LabelSet{"instance": "blackbox"},
+			wantParams: url.Values{"module": []string{"http_2xx"}},
+		},
+		{
+			name:       "url",
+			labels:     model.LabelSet{"__address__": "https://localhost:9090/federate", "__scheme__": "http"},
+			wantURL:    "https://localhost:9090/federate",
+			wantLabels: model.LabelSet{"instance": "https://localhost:9090/federate"},
+		},
+		{
+			name:    "missing address",
+			labels:  model.LabelSet{"job": "node"},
+			wantErr: discovery.ErrMissingAddress,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			scraper, err := discovery.NewScraper(tt.labels)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error mismatch: want(%v) got(%v)", tt.wantErr, err)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if scraper.URL != tt.wantURL {
+				t.Errorf("URL mismatch: want(%s) got(%s)", tt.wantURL, scraper.URL)
+			}
+
+			if diff := cmp.Diff(tt.wantLabels, scraper.Labels); diff != "" {
+				t.Errorf("labels mismatch (-want +got):\n%s", diff)
+			}
+
+			if diff := cmp.Diff(tt.wantParams, scraper.Params); diff != "" {
+				t.Errorf("params mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestManager(t *testing.T) {
+	t.Parallel()
+
+	collector := promaggr.NewCollector(nil)
+	discoverers := map[string]discovery.Discoverer{
+		"static": discovery.StaticDiscoverer{
+			{
+				Targets: []model.LabelSet{
+					{"__address__": "localhost:9100"},
+					{"__address__": "localhost:9101", "drop": "true"},
+					{"__address__": "localhost:9102", "env": "dev"},
+				},
+				Labels: model.LabelSet{"env": "prod"},
+			},
+		},
+	}
+
+	manager := discovery.NewManager(collector, discoverers,
+		discovery.ScraperOptions(promaggr.SampleLimit(100)),
+		discovery.Relabel(func(labels model.LabelSet) model.LabelSet {
+			if labels["drop"] == "true" {
+				return nil
+			}
+
+			return labels
+		}),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go manager.Run(ctx)
+
+	want := []string{
+		`http://localhost:9100/metrics{env="prod", instance="localhost:9100"}`,
+		`http://localhost:9102/metrics{env="dev", instance="localhost:9102"}`,
+	}
+
+	got := waitForScrapers(t, collector, len(want))
+
+	if diff := cmp.Diff(want, scraperIDs(got)); diff != "" {
+		t.Errorf("scraper IDs mismatch (-want +got):\n%s", diff)
+	}
+
+	for _, scraper := range got {
+		if scraper.SampleLimit != 100 {
+			t.Errorf("sample limit mismatch: want(100) got(%d)", scraper.SampleLimit)
+		}
+	}
+}
+
+func waitForScrapers(t *testing.T, collector *promaggr.Collector, n int) []*promaggr.Scraper {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+
+	for {
+		scrapers := collector.ScraperList()
+		if len(scrapers) == n {
+			return scrapers
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("number of scrapers mismatch: want(%d) got(%d)", n, len(scrapers))
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func scraperIDs(scrapers []*promaggr.Scraper) []string {
+	ids := make([]string, 0, len(scrapers))
+	for _, scraper := range scrapers {
+		ids = append(ids, scraper.ID())
+	}
+
+	return ids
+}
@@ -0,0 +1,218 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/common/model"
+)
+
+const (
+	// DNSNameLabel is the meta label holding the DNS name the target was resolved from.
+	DNSNameLabel model.LabelName = MetaLabelPrefix + "dns_name"
+
+	// DNSSRVRecordTargetLabel is the meta label holding the target of the SRV record.
+	DNSSRVRecordTargetLabel model.LabelName = MetaLabelPrefix + "dns_srv_record_target"
+
+	// DNSSRVRecordPortLabel is the meta label holding the port of the SRV record.
+	DNSSRVRecordPortLabel model.LabelName = MetaLabelPrefix + "dns_srv_record_port"
+
+	defaultDNSRefreshInterval = 30 * time.Second
+)
+
+// DNSRecordType is the type of the DNS records to resolve.
+type DNSRecordType string
+
+const (
+	// DNSRecordTypeSRV resolves SRV records, whose targets and ports are used as the addresses.
+	DNSRecordTypeSRV DNSRecordType = "SRV"
+
+	// DNSRecordTypeA resolves A records, whose IP addresses are used with the port of the DNSDiscoverer.
+	DNSRecordTypeA DNSRecordType = "A"
+
+	// DNSRecordTypeAAAA resolves AAAA records, whose IP addresses are used with the port of the DNSDiscoverer.
+	DNSRecordTypeAAAA DNSRecordType = "AAAA"
+)
+
+var (
+	// ErrUnsupportedDNSRecordType is returned when the type of the DNS records is not supported.
+	ErrUnsupportedDNSRecordType = errors.New("unsupported DNS record type")
+
+	// ErrMissingPort is returned when the port is not specified for the A or AAAA records.
+	ErrMissingPort = errors.New("port is required for A and AAAA records")
+)
+
+// Resolver resolves DNS names. *net.Resolver implements this interface.
+type Resolver interface {
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+var (
+	_ Resolver   = &net.Resolver{}
+	_ Discoverer = &DNSDiscoverer{}
+)
+
+// DNSDiscovererOption is a functional option used by the NewDNSDiscoverer.
+type DNSDiscovererOption func(*DNSDiscoverer)
+
+// DNSDiscoverer finds targets by resolving DNS names at an interval.
+// The DNSNameLabel is added to each target,
+// and the DNSSRVRecordTargetLabel and DNSSRVRecordPortLabel are added to the targets of SRV records.
+type DNSDiscoverer struct {
+	// Names is a list of DNS names to resolve.
+	Names []string
+
+	// Type is the type of the DNS records to resolve. If not specified, SRV will be used.
+	Type DNSRecordType
+
+	// Port is the port of the targets resolved from the A or AAAA records.
+	Port int
+
+	// RefreshInterval is the interval to resolve the names.
+	// If not specified, 30 seconds will be used.
+	RefreshInterval time.Duration
+
+	// Resolver is used to resolve the names.
+	// If not specified, net.DefaultResolver will be used.
+	Resolver Resolver
+
+	// Logger is a logger that implements the logr.Logger interface.
+	// If it is not specified, nothing will be logged.
+	Logger logr.Logger
+}
+
+// NewDNSDiscoverer creates and returns a new DNSDiscoverer which resolves the names.
+func NewDNSDiscoverer(names []string, opts ...DNSDiscovererOption) *DNSDiscoverer {
+	discoverer := &DNSDiscoverer{
+		Names: names,
+	}
+
+	for _, o := range opts {
+		o(discoverer)
+	}
+
+	return discoverer
+}
+
+// DNSType is an option available for NewDNSDiscoverer.
+// The DNS records of the given type are resolved. The port is used for the A or AAAA records.
+func DNSType(recordType DNSRecordType, port int) DNSDiscovererOption {
+	return func(d *DNSDiscoverer) {
+		d.Type = recordType
+		d.Port = port
+	}
+}
+
+// DNSRefreshInterval is an option available for NewDNSDiscoverer.
+// The names are resolved at the given interval.
+func DNSRefreshInterval(interval time.Duration) DNSDiscovererOption {
+	return func(d *DNSDiscoverer) {
+		d.RefreshInterval = interval
+	}
+}
+
+// DNSResolver is an option available for NewDNSDiscoverer.
+// The names are resolved by the given Resolver.
+func DNSResolver(resolver Resolver) DNSDiscovererOption {
+	return func(d *DNSDiscoverer) {
+		d.Resolver = resolver
+	}
+}
+
+// DNSLogger is an option available for NewDNSDiscoverer.
+// If a logger is set, the names that cannot be resolved will be output to the log.
+func DNSLogger(logger logr.Logger) DNSDiscovererOption {
+	return func(d *DNSDiscoverer) {
+		d.Logger = logger
+	}
+}
+
+// Run implements the Discoverer interface.
+// If a name cannot be resolved, an empty target group is sent for it so that its previous targets are dropped.
+func (d *DNSDiscoverer) Run(ctx context.Context, ch chan<- []*TargetGroup) {
+	interval := d.RefreshInterval
+	if interval <= 0 {
+		interval = defaultDNSRefreshInterval
+	}
+
+	runRefresh(ctx, interval, func(ctx context.Context) ([]*TargetGroup, bool) {
+		groups := make([]*TargetGroup, 0, len(d.Names))
+
+		for _, name := range d.Names {
+			group, err := d.resolve(ctx, name)
+			if err != nil {
+				if d.Logger != nil {
+					d.Logger.Error(err, "failed to resolve DNS name", "name", name)
+				}
+
+				group = &TargetGroup{Source: name}
+			}
+
+			groups = append(groups, group)
+		}
+
+		return groups, true
+	}, ch)
+}
+
+// resolve resolves the name into a target group.
+func (d *DNSDiscoverer) resolve(ctx context.Context, name string) (*TargetGroup, error) {
+	resolver := d.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+
+	group := &TargetGroup{
+		Labels: model.LabelSet{DNSNameLabel: model.LabelValue(name)},
+		Source: name,
+	}
+
+	switch d.Type {
+	case DNSRecordTypeSRV, "":
+		_, records, err := resolver.LookupSRV(ctx, "", "", name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up SRV records of %s: %w", name, err)
+		}
+
+		for _, record := range records {
+			target := strings.TrimSuffix(record.Target, ".")
+			port := strconv.Itoa(int(record.Port))
+
+			group.Targets = append(group.Targets, model.LabelSet{
+				AddressLabel:            model.LabelValue(net.JoinHostPort(target, port)),
+				DNSSRVRecordTargetLabel: model.LabelValue(record.Target),
+				DNSSRVRecordPortLabel:   model.LabelValue(port),
+			})
+		}
+	case DNSRecordTypeA, DNSRecordTypeAAAA:
+		if d.Port == 0 {
+			return nil, ErrMissingPort
+		}
+
+		addrs, err := resolver.LookupIPAddr(ctx, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up %s records of %s: %w", d.Type, name, err)
+		}
+
+		for _, addr := range addrs {
+			if isIPv4 := addr.IP.To4() != nil; isIPv4 != (d.Type == DNSRecordTypeA) {
+				continue
+			}
+
+			group.Targets = append(group.Targets, model.LabelSet{
+				AddressLabel: model.LabelValue(net.JoinHostPort(addr.IP.String(), strconv.Itoa(d.Port))),
+			})
+		}
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedDNSRecordType, d.Type)
+	}
+
+	return group, nil
+}
@@ -0,0 +1,211 @@
+package discovery_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/d-kuro/promaggr/discovery"
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/common/model"
+)
+
+var errNoSuchHost = errors.New("no such host")
+
+// stubResolver resolves the names from the fixed records.
+type stubResolver struct {
+	mutex sync.Mutex
+	srv   map[string][]*net.SRV
+	ip    map[string][]net.IPAddr
+}
+
+func (r *stubResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	records, ok := r.srv[name]
+	if !ok {
+		return "", nil, errNoSuchHost
+	}
+
+	return name, records, nil
+}
+
+func (r *stubResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	addrs, ok := r.ip[host]
+	if !ok {
+		return nil, errNoSuchHost
+	}
+
+	return addrs, nil
+}
+
+func (r *stubResolver) setSRV(name string, records []*net.SRV) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.srv[name] = records
+}
+
+func (r *stubResolver) deleteSRV(name string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.srv, name)
+}
+
+func TestDNSDiscovererSRV(t *testing.T) {
+	t.Parallel()
+
+	resolver := &stubResolver{
+		srv: map[string][]*net.SRV{
+			"_metrics._tcp.example.com": {
+				{Target: "node1.example.com.", Port: 9100},
+				{Target: "node2.example.com.", Port: 9100},
+			},
+		},
+	}
+
+	discoverer := discovery.NewDNSDiscoverer(
+		[]string{"_metrics._tcp.example.com", "_unknown._tcp.example.com"},
+		discovery.DNSResolver(resolver),
+		discovery.DNSRefreshInterval(10*time.Millisecond),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch := make(chan []*discovery.TargetGroup)
+	go discoverer.Run(ctx, ch)
+
+	want := []*discovery.TargetGroup{
+		{
+			Targets: []model.LabelSet{
+				{
+					"__address__":                  "node1.example.com:9100",
+					"__meta_dns_srv_record_target": "node1.example.com.",
+					"__meta_dns_srv_record_port":   "9100",
+				},
+				{
+					"__address__":                  "node2.example.com:9100",
+					"__meta_dns_srv_record_target": "node2.example.com.",
+					"__meta_dns_srv_record_port":   "9100",
+				},
+			},
+			Labels: model.LabelSet{"__meta_dns_name": "_metrics._tcp.example.com"},
+			Source: "_metrics._tcp.example.com",
+		},
+		{
+			Source: "_unknown._tcp.example.com",
+		},
+	}
+
+	if diff := cmp.Diff(want, receive(t, ch)); diff != "" {
+		t.Errorf("target groups mismatch (-want +got):\n%s", diff)
+	}
+
+	resolver.setSRV("_metrics._tcp.example.com", []*net.SRV{{Target: "node3.example.com.", Port: 9100}})
+
+	want[0].Targets = []model.LabelSet{
+		{
+			"__address__":                  "node3.example.com:9100",
+			"__meta_dns_srv_record_target": "node3.example.com.",
+			"__meta_dns_srv_record_port":   "9100",
+		},
+	}
+
+	receiveUntil(t, ch, want)
+
+	// The targets of a name which can no longer be resolved are dropped.
+	resolver.deleteSRV("_metrics._tcp.example.com")
+
+	want[0] = &discovery.TargetGroup{Source: "_metrics._tcp.example.com"}
+
+	receiveUntil(t, ch, want)
+}
+
+// receiveUntil receives the target groups until they are the same as want.
+// The update may be received after the groups before the change.
+func receiveUntil(t *testing.T, ch <-chan []*discovery.TargetGroup, want []*discovery.TargetGroup) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+
+	for {
+		diff := cmp.Diff(want, receive(t, ch))
+		if diff == "" {
+			return
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("target groups mismatch (-want +got):\n%s", diff)
+		}
+	}
+}
+
+func TestDNSDiscovererA(t *testing.T) {
+	t.Parallel()
+
+	resolver := &stubResolver{
+		ip: map[string][]net.IPAddr{
+			"exporter.example.com": {
+				{IP: net.ParseIP("192.0.2.1")},
+				{IP: net.ParseIP("2001:db8::1")},
+			},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		recordType discovery.DNSRecordType
+		want       model.LabelValue
+	}{
+		{
+			name:       "A",
+			recordType: discovery.DNSRecordTypeA,
+			want:       "192.0.2.1:9100",
+		},
+		{
+			name:       "AAAA",
+			recordType: discovery.DNSRecordTypeAAAA,
+			want:       "[2001:db8::1]:9100",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			discoverer := discovery.NewDNSDiscoverer(
+				[]string{"exporter.example.com"},
+				discovery.DNSType(tt.recordType, 9100),
+				discovery.DNSResolver(resolver),
+			)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			ch := make(chan []*discovery.TargetGroup)
+			go discoverer.Run(ctx, ch)
+
+			want := []*discovery.TargetGroup{
+				{
+					Targets: []model.LabelSet{{"__address__": tt.want}},
+					Labels:  model.LabelSet{"__meta_dns_name": "exporter.example.com"},
+					Source:  "exporter.example.com",
+				},
+			}
+
+			if diff := cmp.Diff(want, receive(t, ch)); diff != "" {
+				t.Errorf("target groups mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
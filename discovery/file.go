@@ -0,0 +1,263 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/common/model"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// FilePathLabel is the meta label holding the path of the file the target was read from.
+	FilePathLabel model.LabelName = MetaLabelPrefix + "filepath"
+
+	defaultFileRefreshInterval = 5 * time.Second
+)
+
+// ErrUnsupportedFileExtension is returned when the extension of a file is neither .json, .yml nor .yaml.
+var ErrUnsupportedFileExtension = errors.New("unsupported file extension")
+
+var _ Discoverer = &FileDiscoverer{}
+
+// FileDiscovererOption is a functional option used by the NewFileDiscoverer.
+type FileDiscovererOption func(*FileDiscoverer)
+
+// FileDiscoverer finds targets from the files in the format of the file_sd of Prometheus.
+// Each file is a JSON or YAML list of target groups, like:
+//
+//	[{"targets": ["localhost:9100"], "labels": {"job": "node"}}]
+//
+// The FilePathLabel is added to each target group.
+type FileDiscoverer struct {
+	// Files is a list of patterns of the files to read, e.g. "/etc/promaggr/targets/*.json".
+	Files []string
+
+	// RefreshInterval is the interval to check whether the files have been changed.
+	// The files are read again only if their modification time or size has been changed.
+	// If not specified, 5 seconds will be used.
+	RefreshInterval time.Duration
+
+	// Logger is a logger that implements the logr.Logger interface.
+	// If it is not specified, nothing will be logged.
+	Logger logr.Logger
+}
+
+// NewFileDiscoverer creates and returns a new FileDiscoverer which reads the files matching the patterns.
+func NewFileDiscoverer(files []string, opts ...FileDiscovererOption) *FileDiscoverer {
+	discoverer := &FileDiscoverer{
+		Files: files,
+	}
+
+	for _, o := range opts {
+		o(discoverer)
+	}
+
+	return discoverer
+}
+
+// FileRefreshInterval is an option available for NewFileDiscoverer.
+// The files are checked for changes at the given interval.
+func FileRefreshInterval(interval time.Duration) FileDiscovererOption {
+	return func(d *FileDiscoverer) {
+		d.RefreshInterval = interval
+	}
+}
+
+// FileLogger is an option available for NewFileDiscoverer.
+// If a logger is set, the files that cannot be read will be output to the log.
+func FileLogger(logger logr.Logger) FileDiscovererOption {
+	return func(d *FileDiscoverer) {
+		d.Logger = logger
+	}
+}
+
+// fileState is the state of a file used to detect changes.
+type fileState struct {
+	modTime time.Time
+	size    int64
+	groups  []*TargetGroup
+}
+
+// Run implements the Discoverer interface.
+// If a file cannot be read, the target groups previously read from it are kept.
+func (d *FileDiscoverer) Run(ctx context.Context, ch chan<- []*TargetGroup) {
+	interval := d.RefreshInterval
+	if interval <= 0 {
+		interval = defaultFileRefreshInterval
+	}
+
+	var states map[string]fileState
+
+	runRefresh(ctx, interval, func(ctx context.Context) ([]*TargetGroup, bool) {
+		newStates, changed := d.refresh(states)
+		if !changed && states != nil {
+			return nil, false
+		}
+
+		states = newStates
+
+		return targetGroups(states), true
+	}, ch)
+}
+
+// refresh reads the files that have been changed since the previous states.
+// It reports whether the target groups of any file have been changed, added or removed.
+func (d *FileDiscoverer) refresh(states map[string]fileState) (map[string]fileState, bool) {
+	paths := d.paths()
+	newStates := make(map[string]fileState, len(paths))
+	changed := len(paths) != len(states)
+
+	for _, path := range paths {
+		state, ok := states[path]
+
+		info, err := os.Stat(path)
+		if err != nil {
+			d.logError(err, "failed to stat file", path)
+
+			newStates[path] = state
+
+			continue
+		}
+
+		if ok && info.ModTime().Equal(state.modTime) && info.Size() == state.size {
+			newStates[path] = state
+
+			continue
+		}
+
+		groups, err := readTargetGroups(path)
+		if err != nil {
+			d.logError(err, "failed to read file", path)
+
+			newStates[path] = fileState{modTime: info.ModTime(), size: info.Size(), groups: state.groups}
+
+			continue
+		}
+
+		changed = true
+		newStates[path] = fileState{modTime: info.ModTime(), size: info.Size(), groups: groups}
+	}
+
+	return newStates, changed
+}
+
+// paths returns the paths of the files matching the patterns.
+func (d *FileDiscoverer) paths() []string {
+	seen := make(map[string]struct{})
+
+	var paths []string
+
+	for _, pattern := range d.Files {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			d.logError(err, "invalid file pattern", pattern)
+
+			continue
+		}
+
+		for _, path := range matches {
+			if _, ok := seen[path]; ok {
+				continue
+			}
+
+			seen[path] = struct{}{}
+			paths = append(paths, path)
+		}
+	}
+
+	return paths
+}
+
+func (d *FileDiscoverer) logError(err error, msg, path string) {
+	if d.Logger != nil {
+		d.Logger.Error(err, msg, "path", path)
+	}
+}
+
+// targetGroups returns the target groups of all files sorted by the path.
+func targetGroups(states map[string]fileState) []*TargetGroup {
+	paths := make([]string, 0, len(states))
+	for path := range states {
+		paths = append(paths, path)
+	}
+
+	sort.Strings(paths)
+
+	var groups []*TargetGroup
+	for _, path := range paths {
+		groups = append(groups, states[path].groups...)
+	}
+
+	return groups
+}
+
+// fileTargetGroup is a target group in the format of the file_sd and the http_sd of Prometheus.
+type fileTargetGroup struct {
+	Targets []string       `json:"targets" yaml:"targets"`
+	Labels  model.LabelSet `json:"labels" yaml:"labels"`
+}
+
+// readTargetGroups reads the target groups from the JSON or YAML file.
+func readTargetGroups(path string) ([]*TargetGroup, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var fileGroups []fileTargetGroup
+
+	switch ext := filepath.Ext(path); ext {
+	case ".json":
+		err = json.Unmarshal(b, &fileGroups)
+	case ".yml", ".yaml":
+		err = yaml.UnmarshalStrict(b, &fileGroups)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFileExtension, path)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	return parseTargetGroups(fileGroups, path, FilePathLabel)
+}
+
+// parseTargetGroups converts the target groups in a file into TargetGroups.
+// The source is added to each target group as the sourceLabel.
+func parseTargetGroups(fileGroups []fileTargetGroup, source string, sourceLabel model.LabelName) ([]*TargetGroup, error) {
+	groups := make([]*TargetGroup, 0, len(fileGroups))
+
+	for i, fileGroup := range fileGroups {
+		if err := fileGroup.Labels.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid labels of group %d in %s: %w", i, source, err)
+		}
+
+		group := &TargetGroup{
+			Targets: make([]model.LabelSet, 0, len(fileGroup.Targets)),
+			Labels:  fileGroup.Labels.Clone(),
+			Source:  fmt.Sprintf("%s:%d", source, i),
+		}
+
+		if group.Labels == nil {
+			group.Labels = model.LabelSet{}
+		}
+
+		group.Labels[sourceLabel] = model.LabelValue(source)
+
+		for _, target := range fileGroup.Targets {
+			group.Targets = append(group.Targets, model.LabelSet{AddressLabel: model.LabelValue(target)})
+		}
+
+		groups = append(groups, group)
+	}
+
+	return groups, nil
+}
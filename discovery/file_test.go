@@ -0,0 +1,96 @@
+package discovery_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/d-kuro/promaggr/discovery"
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/common/model"
+)
+
+func TestFileDiscoverer(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	jsonFile := filepath.Join(dir, "targets.json")
+	yamlFile := filepath.Join(dir, "targets.yml")
+
+	writeFile(t, jsonFile, `[{"targets": ["localhost:9100", "localhost:9101"], "labels": {"job": "node"}}]`)
+	writeFile(t, yamlFile, "- targets: [localhost:9090]\n  labels:\n    job: prometheus\n")
+
+	discoverer := discovery.NewFileDiscoverer(
+		[]string{filepath.Join(dir, "*.json"), filepath.Join(dir, "*.yml")},
+		discovery.FileRefreshInterval(10*time.Millisecond),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch := make(chan []*discovery.TargetGroup)
+	go discoverer.Run(ctx, ch)
+
+	want := []*discovery.TargetGroup{
+		{
+			Targets: []model.LabelSet{{"__address__": "localhost:9100"}, {"__address__": "localhost:9101"}},
+			Labels:  model.LabelSet{"job": "node", "__meta_filepath": model.LabelValue(jsonFile)},
+			Source:  jsonFile + ":0",
+		},
+		{
+			Targets: []model.LabelSet{{"__address__": "localhost:9090"}},
+			Labels:  model.LabelSet{"job": "prometheus", "__meta_filepath": model.LabelValue(yamlFile)},
+			Source:  yamlFile + ":0",
+		},
+	}
+
+	if diff := cmp.Diff(want, receive(t, ch)); diff != "" {
+		t.Errorf("target groups mismatch (-want +got):\n%s", diff)
+	}
+
+	writeFile(t, jsonFile, `[{"targets": ["localhost:9100"], "labels": {"job": "node-exporter"}}]`)
+
+	want[0] = &discovery.TargetGroup{
+		Targets: []model.LabelSet{{"__address__": "localhost:9100"}},
+		Labels:  model.LabelSet{"job": "node-exporter", "__meta_filepath": model.LabelValue(jsonFile)},
+		Source:  jsonFile + ":0",
+	}
+
+	if diff := cmp.Diff(want, receive(t, ch)); diff != "" {
+		t.Errorf("target groups mismatch (-want +got):\n%s", diff)
+	}
+
+	// The target groups of a file that cannot be parsed are kept.
+	writeFile(t, jsonFile, `[{"targets": "localhost:9100"`)
+
+	if err := os.Remove(yamlFile); err != nil {
+		t.Fatalf("failed to remove file: %v", err)
+	}
+
+	if diff := cmp.Diff(want[:1], receive(t, ch)); diff != "" {
+		t.Errorf("target groups mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+}
+
+func receive(t *testing.T, ch <-chan []*discovery.TargetGroup) []*discovery.TargetGroup {
+	t.Helper()
+
+	select {
+	case groups := <-ch:
+		return groups
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for target groups")
+
+		return nil
+	}
+}
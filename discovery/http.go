@@ -0,0 +1,164 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/common/model"
+)
+
+const (
+	// URLLabel is the meta label holding the URL the target was fetched from.
+	URLLabel model.LabelName = MetaLabelPrefix + "url"
+
+	defaultHTTPRefreshInterval = 60 * time.Second
+
+	// maxResponseSize is the maximum size of the JSON response read from the discovery server.
+	maxResponseSize = 10 << 20
+
+	// maxDrainSize is the maximum size of the rest of the response read to reuse the connection.
+	maxDrainSize = 4 << 10
+)
+
+// ErrUnexpectedStatusCode is returned when the discovery server responds with a status code other than 200.
+var ErrUnexpectedStatusCode = errors.New("unexpected status code")
+
+var _ Discoverer = &HTTPDiscoverer{}
+
+// HTTPDiscovererOption is a functional option used by the NewHTTPDiscoverer.
+type HTTPDiscovererOption func(*HTTPDiscoverer)
+
+// HTTPDiscoverer finds targets by fetching a URL at an interval.
+// The URL responds with a JSON list of target groups in the format of the http_sd of Prometheus, like:
+//
+//	[{"targets": ["localhost:9100"], "labels": {"job": "node"}}]
+//
+// The URLLabel is added to each target group.
+type HTTPDiscoverer struct {
+	// URL is the URL to fetch the target groups from.
+	URL string
+
+	// RefreshInterval is the interval to fetch the URL.
+	// If not specified, 60 seconds will be used.
+	RefreshInterval time.Duration
+
+	// HTTPClient is the http.Client to be used for the request. If not specified, the http.DefaultClient will be used.
+	HTTPClient *http.Client
+
+	// Logger is a logger that implements the logr.Logger interface.
+	// If it is not specified, nothing will be logged.
+	Logger logr.Logger
+}
+
+// NewHTTPDiscoverer creates and returns a new HTTPDiscoverer which fetches the URL.
+func NewHTTPDiscoverer(url string, opts ...HTTPDiscovererOption) *HTTPDiscoverer {
+	discoverer := &HTTPDiscoverer{
+		URL: url,
+	}
+
+	for _, o := range opts {
+		o(discoverer)
+	}
+
+	return discoverer
+}
+
+// HTTPRefreshInterval is an option available for NewHTTPDiscoverer.
+// The URL is fetched at the given interval.
+func HTTPRefreshInterval(interval time.Duration) HTTPDiscovererOption {
+	return func(d *HTTPDiscoverer) {
+		d.RefreshInterval = interval
+	}
+}
+
+// HTTPClient is an option available for NewHTTPDiscoverer.
+// The URL is fetched by the given client, e.g. to configure the TLS or the authorization.
+func HTTPClient(client *http.Client) HTTPDiscovererOption {
+	return func(d *HTTPDiscoverer) {
+		d.HTTPClient = client
+	}
+}
+
+// HTTPLogger is an option available for NewHTTPDiscoverer.
+// If a logger is set, the errors while fetching the URL will be output to the log.
+func HTTPLogger(logger logr.Logger) HTTPDiscovererOption {
+	return func(d *HTTPDiscoverer) {
+		d.Logger = logger
+	}
+}
+
+// Run implements the Discoverer interface.
+// If the URL cannot be fetched, the target groups previously fetched from it are kept.
+func (d *HTTPDiscoverer) Run(ctx context.Context, ch chan<- []*TargetGroup) {
+	interval := d.RefreshInterval
+	if interval <= 0 {
+		interval = defaultHTTPRefreshInterval
+	}
+
+	var groups []*TargetGroup
+
+	runRefresh(ctx, interval, func(ctx context.Context) ([]*TargetGroup, bool) {
+		fetched, err := d.fetch(ctx)
+		if err != nil {
+			if d.Logger != nil {
+				d.Logger.Error(err, "failed to fetch target groups", "url", d.URL)
+			}
+		} else {
+			groups = fetched
+		}
+
+		return groups, true
+	}, ch)
+}
+
+// fetch fetches the target groups from the URL.
+func (d *HTTPDiscoverer) fetch(ctx context.Context) ([]*TargetGroup, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request to %s: %w", d.URL, err)
+	}
+
+	req.Header.Set("Accept", "application/json")
+
+	var fileGroups []fileTargetGroup
+	if err := getJSON(d.HTTPClient, req, &fileGroups); err != nil {
+		return nil, err
+	}
+
+	return parseTargetGroups(fileGroups, d.URL, URLLabel)
+}
+
+// getJSON sends the request and decodes the JSON response into v.
+// The response is read up to maxResponseSize, and larger responses fail to be decoded.
+// Only a small rest of the response is drained, so that an endless response does not block.
+func getJSON(client *http.Client, req *http.Request, v interface{}) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to request to %s: %w", req.URL, err)
+	}
+
+	defer func() {
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxDrainSize))
+		resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to request to %s: %w: %d", req.URL, ErrUnexpectedStatusCode, resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode response from %s: %w", req.URL, err)
+	}
+
+	return nil
+}
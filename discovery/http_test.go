@@ -0,0 +1,131 @@
+package discovery_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/d-kuro/promaggr/discovery"
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/common/model"
+)
+
+func TestHTTPDiscoverer(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mutex sync.Mutex
+		body  = `[{"targets": ["localhost:9100"], "labels": {"job": "node"}}]`
+	)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		if body == "" {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(body))
+	}))
+	defer ts.Close()
+
+	setBody := func(b string) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		body = b
+	}
+
+	discoverer := discovery.NewHTTPDiscoverer(ts.URL, discovery.HTTPRefreshInterval(10*time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch := make(chan []*discovery.TargetGroup)
+	go discoverer.Run(ctx, ch)
+
+	want := []*discovery.TargetGroup{
+		{
+			Targets: []model.LabelSet{{"__address__": "localhost:9100"}},
+			Labels:  model.LabelSet{"job": "node", "__meta_url": model.LabelValue(ts.URL)},
+			Source:  ts.URL + ":0",
+		},
+	}
+
+	if diff := cmp.Diff(want, receive(t, ch)); diff != "" {
+		t.Errorf("target groups mismatch (-want +got):\n%s", diff)
+	}
+
+	// The previous target groups are kept while the server is unavailable.
+	setBody("")
+
+	for i := 0; i < 3; i++ {
+		if diff := cmp.Diff(want, receive(t, ch)); diff != "" {
+			t.Errorf("target groups mismatch (-want +got):\n%s", diff)
+		}
+	}
+
+	setBody(`[{"targets": ["localhost:9101"]}]`)
+
+	want = []*discovery.TargetGroup{
+		{
+			Targets: []model.LabelSet{{"__address__": "localhost:9101"}},
+			Labels:  model.LabelSet{"__meta_url": model.LabelValue(ts.URL)},
+			Source:  ts.URL + ":0",
+		},
+	}
+
+	// The update may be received after the groups before the change.
+	deadline := time.Now().Add(5 * time.Second)
+
+	for {
+		diff := cmp.Diff(want, receive(t, ch))
+		if diff == "" {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("target groups mismatch (-want +got):\n%s", diff)
+		}
+	}
+}
+
+func TestHTTPDiscovererEndlessResponse(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		// The response is larger than the limit and never ends until the client closes the connection.
+		_, _ = w.Write([]byte("["))
+		padding := bytes.Repeat([]byte(" "), 32<<10)
+
+		for {
+			if _, err := w.Write(padding); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(ts.Close)
+
+	discoverer := discovery.NewHTTPDiscoverer(ts.URL, discovery.HTTPRefreshInterval(time.Hour))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch := make(chan []*discovery.TargetGroup)
+	go discoverer.Run(ctx, ch)
+
+	// The refresh fails and returns without reading the whole response.
+	if got := receive(t, ch); len(got) != 0 {
+		t.Errorf("number of target groups mismatch: want(0) got(%d)", len(got))
+	}
+}
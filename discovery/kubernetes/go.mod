@@ -0,0 +1,18 @@
+module github.com/d-kuro/promaggr/discovery/kubernetes
+
+go 1.16
+
+require (
+	github.com/d-kuro/promaggr v0.0.0-20261018192236-67fce2163549
+	github.com/go-logr/logr v0.4.0
+	github.com/google/go-cmp v0.5.6
+	github.com/prometheus/common v0.29.0
+	k8s.io/api v0.21.14
+	k8s.io/apimachinery v0.21.14
+	k8s.io/client-go v0.21.14
+)
+
+// The replace is only applied when developing in this repository.
+// The required version of github.com/d-kuro/promaggr must be a released one that contains what this module uses:
+// tag the root module first, then update the requirement and tag this module as discovery/kubernetes/vX.Y.Z.
+replace github.com/d-kuro/promaggr => ../..
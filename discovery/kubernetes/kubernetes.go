@@ -0,0 +1,489 @@
+// Package kubernetes provides a discovery.Discoverer which finds the pods and endpoints to scrape in Kubernetes.
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/d-kuro/promaggr/discovery"
+	"github.com/go-logr/logr"
+	"github.com/prometheus/common/model"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// ScrapeAnnotation is the annotation to opt in to be scraped. Only the value "true" is accepted.
+	ScrapeAnnotation = "prometheus.io/scrape"
+
+	// PortAnnotation is the annotation specifying the port to scrape.
+	// If not specified, all TCP ports of the pod or endpoints are scraped.
+	PortAnnotation = "prometheus.io/port"
+
+	// PathAnnotation is the annotation specifying the path to scrape. The default is "/metrics".
+	PathAnnotation = "prometheus.io/path"
+
+	// SchemeAnnotation is the annotation specifying the scheme used to scrape. The default is "http".
+	SchemeAnnotation = "prometheus.io/scheme"
+
+	// NamespaceLabel is the label holding the namespace of a target in the scraped metrics.
+	NamespaceLabel model.LabelName = "namespace"
+
+	// PodLabel is the label holding the name of the pod of a target in the scraped metrics.
+	PodLabel model.LabelName = "pod"
+
+	// ContainerLabel is the label holding the name of the container of a target in the scraped metrics.
+	ContainerLabel model.LabelName = "container"
+
+	metaLabelPrefix = discovery.MetaLabelPrefix + "kubernetes_"
+
+	namespaceMetaLabel               = metaLabelPrefix + "namespace"
+	podNameMetaLabel                 = metaLabelPrefix + "pod_name"
+	podIPMetaLabel                   = metaLabelPrefix + "pod_ip"
+	podNodeNameMetaLabel             = metaLabelPrefix + "pod_node_name"
+	podContainerNameMetaLabel        = metaLabelPrefix + "pod_container_name"
+	podContainerPortNameMetaLabel    = metaLabelPrefix + "pod_container_port_name"
+	podLabelMetaLabelPrefix          = metaLabelPrefix + "pod_label_"
+	podAnnotationMetaLabelPrefix     = metaLabelPrefix + "pod_annotation_"
+	serviceNameMetaLabel             = metaLabelPrefix + "service_name"
+	serviceLabelMetaLabelPrefix      = metaLabelPrefix + "service_label_"
+	serviceAnnotationMetaLabelPrefix = metaLabelPrefix + "service_annotation_"
+	endpointsNameMetaLabel           = metaLabelPrefix + "endpoints_name"
+)
+
+// Role is the kind of the Kubernetes objects to discover.
+type Role string
+
+const (
+	// RolePod discovers the pods annotated with the ScrapeAnnotation.
+	RolePod Role = "pod"
+
+	// RoleEndpoints discovers the endpoints of the services annotated with the ScrapeAnnotation.
+	RoleEndpoints Role = "endpoints"
+)
+
+// ErrUnsupportedRole is returned when the role is not supported.
+var ErrUnsupportedRole = errors.New("unsupported role")
+
+var _ discovery.Discoverer = &Discoverer{}
+
+// Option is a functional option used by the NewDiscoverer.
+type Option func(*Discoverer)
+
+// Discoverer finds the pods or endpoints to scrape in Kubernetes, and watches their changes.
+// The NamespaceLabel, PodLabel and ContainerLabel are added to each target,
+// along with the meta labels prefixed with "__meta_kubernetes_" which can be used for relabeling.
+type Discoverer struct {
+	// Client is the client of the Kubernetes API.
+	Client clientset.Interface
+
+	// Role is the kind of the objects to discover.
+	Role Role
+
+	// Namespaces is a list of namespaces to discover the objects in.
+	// If not specified, all namespaces will be used.
+	Namespaces []string
+
+	// LabelSelector selects the pods or services to discover by their labels.
+	// If not specified, all objects will be used.
+	LabelSelector string
+
+	// Logger is a logger that implements the logr.Logger interface.
+	// If it is not specified, nothing will be logged.
+	Logger logr.Logger
+}
+
+// NewDiscoverer creates and returns a new Discoverer which finds the objects of the role.
+func NewDiscoverer(client clientset.Interface, role Role, opts ...Option) *Discoverer {
+	discoverer := &Discoverer{
+		Client: client,
+		Role:   role,
+	}
+
+	for _, o := range opts {
+		o(discoverer)
+	}
+
+	return discoverer
+}
+
+// Namespaces is an option available for NewDiscoverer.
+// The objects are discovered only in the given namespaces.
+func Namespaces(namespaces ...string) Option {
+	return func(d *Discoverer) {
+		d.Namespaces = namespaces
+	}
+}
+
+// LabelSelector is an option available for NewDiscoverer.
+// Only the pods or services matching the given label selector are discovered.
+func LabelSelector(selector string) Option {
+	return func(d *Discoverer) {
+		d.LabelSelector = selector
+	}
+}
+
+// Logger is an option available for NewDiscoverer.
+// If a logger is set, the errors while discovering will be output to the log.
+func Logger(logger logr.Logger) Option {
+	return func(d *Discoverer) {
+		d.Logger = logger
+	}
+}
+
+// listers holds the listers of the informers of a namespace.
+type listers struct {
+	pods      func() ([]*corev1.Pod, error)
+	services  func() ([]*corev1.Service, error)
+	endpoints func() ([]*corev1.Endpoints, error)
+}
+
+// Run implements the discovery.Discoverer interface.
+// It watches the objects, and sends the target groups whenever they change.
+func (d *Discoverer) Run(ctx context.Context, ch chan<- []*discovery.TargetGroup) {
+	if d.Role != RolePod && d.Role != RoleEndpoints {
+		d.logError(fmt.Errorf("%w: %s", ErrUnsupportedRole, d.Role), "failed to run kubernetes discovery")
+
+		return
+	}
+
+	namespaces := d.Namespaces
+	if len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+
+	// changed is notified when any object is changed. The changes are coalesced while sending the target groups.
+	changed := make(chan struct{}, 1)
+	handler := cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(interface{}) { notify(changed) },
+		UpdateFunc: func(interface{}, interface{}) { notify(changed) },
+		DeleteFunc: func(interface{}) { notify(changed) },
+	}
+
+	all := make([]listers, 0, len(namespaces))
+
+	for _, namespace := range namespaces {
+		factory := informers.NewSharedInformerFactoryWithOptions(d.Client, 0, informers.WithNamespace(namespace))
+
+		// The label selector is applied to the pods or services, not to the endpoints of the services.
+		selected := informers.NewSharedInformerFactoryWithOptions(d.Client, 0,
+			informers.WithNamespace(namespace),
+			informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+				options.LabelSelector = d.LabelSelector
+			}),
+		)
+
+		var l listers
+
+		switch d.Role {
+		case RolePod:
+			pods := selected.Core().V1().Pods()
+			pods.Informer().AddEventHandler(handler)
+			l.pods = func() ([]*corev1.Pod, error) { return pods.Lister().List(labels.Everything()) }
+		case RoleEndpoints:
+			services := selected.Core().V1().Services()
+			services.Informer().AddEventHandler(handler)
+			l.services = func() ([]*corev1.Service, error) { return services.Lister().List(labels.Everything()) }
+
+			endpoints := factory.Core().V1().Endpoints()
+			endpoints.Informer().AddEventHandler(handler)
+			l.endpoints = func() ([]*corev1.Endpoints, error) { return endpoints.Lister().List(labels.Everything()) }
+
+			pods := factory.Core().V1().Pods()
+			pods.Informer().AddEventHandler(handler)
+			l.pods = func() ([]*corev1.Pod, error) { return pods.Lister().List(labels.Everything()) }
+		}
+
+		for _, f := range []informers.SharedInformerFactory{factory, selected} {
+			f.Start(ctx.Done())
+
+			for informer, synced := range f.WaitForCacheSync(ctx.Done()) {
+				if !synced {
+					d.logError(fmt.Errorf("failed to sync %v", informer), "failed to run kubernetes discovery")
+
+					return
+				}
+			}
+		}
+
+		all = append(all, l)
+	}
+
+	for {
+		groups, err := d.targetGroups(all)
+		if err != nil {
+			d.logError(err, "failed to list kubernetes objects")
+		} else {
+			select {
+			case ch <- groups:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func notify(ch chan<- struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+func (d *Discoverer) logError(err error, msg string) {
+	if d.Logger != nil {
+		d.Logger.Error(err, msg, "role", d.Role)
+	}
+}
+
+// targetGroups builds the target groups from the objects in the listers.
+func (d *Discoverer) targetGroups(all []listers) ([]*discovery.TargetGroup, error) {
+	var groups []*discovery.TargetGroup
+
+	for _, l := range all {
+		pods, err := l.pods()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list pods: %w", err)
+		}
+
+		switch d.Role {
+		case RolePod:
+			for _, pod := range pods {
+				if group := podTargetGroup(pod); group != nil {
+					groups = append(groups, group)
+				}
+			}
+		case RoleEndpoints:
+			services, err := l.services()
+			if err != nil {
+				return nil, fmt.Errorf("failed to list services: %w", err)
+			}
+
+			endpoints, err := l.endpoints()
+			if err != nil {
+				return nil, fmt.Errorf("failed to list endpoints: %w", err)
+			}
+
+			groups = append(groups, endpointsTargetGroups(services, endpoints, pods)...)
+		}
+	}
+
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Source < groups[j].Source })
+
+	return groups, nil
+}
+
+// podTargetGroup returns the target group of the pod, or nil if the pod is not to be scraped.
+func podTargetGroup(pod *corev1.Pod) *discovery.TargetGroup {
+	if pod.Annotations[ScrapeAnnotation] != "true" || pod.Status.PodIP == "" ||
+		pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+		return nil
+	}
+
+	group := &discovery.TargetGroup{
+		Labels: podLabels(pod),
+		Source: "pod/" + pod.Namespace + "/" + pod.Name,
+	}
+	addAnnotatedURL(group.Labels, pod.Annotations)
+
+	addTarget := func(container string, port int32, portName string) {
+		target := model.LabelSet{
+			discovery.AddressLabel:    model.LabelValue(net.JoinHostPort(pod.Status.PodIP, strconv.Itoa(int(port)))),
+			podContainerNameMetaLabel: model.LabelValue(container),
+			ContainerLabel:            model.LabelValue(container),
+		}
+
+		if portName != "" {
+			target[podContainerPortNameMetaLabel] = model.LabelValue(portName)
+		}
+
+		group.Targets = append(group.Targets, target)
+	}
+
+	if port, ok := annotatedPort(pod.Annotations); ok {
+		container, portName := containerOfPort(pod, port)
+		addTarget(container, port, portName)
+
+		return group
+	}
+
+	for _, container := range pod.Spec.Containers {
+		for _, port := range container.Ports {
+			if port.Protocol == corev1.ProtocolTCP || port.Protocol == "" {
+				addTarget(container.Name, port.ContainerPort, port.Name)
+			}
+		}
+	}
+
+	return group
+}
+
+// endpointsTargetGroups returns the target groups of the endpoints of the annotated services.
+func endpointsTargetGroups(services []*corev1.Service, endpoints []*corev1.Endpoints, pods []*corev1.Pod) []*discovery.TargetGroup {
+	podsByName := make(map[string]*corev1.Pod, len(pods))
+	for _, pod := range pods {
+		podsByName[pod.Namespace+"/"+pod.Name] = pod
+	}
+
+	servicesByName := make(map[string]*corev1.Service, len(services))
+	for _, service := range services {
+		if service.Annotations[ScrapeAnnotation] == "true" {
+			servicesByName[service.Namespace+"/"+service.Name] = service
+		}
+	}
+
+	var groups []*discovery.TargetGroup
+
+	for _, ep := range endpoints {
+		service, ok := servicesByName[ep.Namespace+"/"+ep.Name]
+		if !ok {
+			continue
+		}
+
+		group := &discovery.TargetGroup{
+			Labels: serviceLabels(service),
+			Source: "endpoints/" + ep.Namespace + "/" + ep.Name,
+		}
+		group.Labels[endpointsNameMetaLabel] = model.LabelValue(ep.Name)
+
+		annotatedPort, hasAnnotatedPort := annotatedPort(service.Annotations)
+
+		for _, subset := range ep.Subsets {
+			for _, address := range subset.Addresses {
+				var pod *corev1.Pod
+				if ref := address.TargetRef; ref != nil && ref.Kind == "Pod" {
+					pod = podsByName[ref.Namespace+"/"+ref.Name]
+				}
+
+				for _, port := range subset.Ports {
+					if port.Protocol != corev1.ProtocolTCP && port.Protocol != "" {
+						continue
+					}
+
+					if hasAnnotatedPort && port.Port != annotatedPort {
+						continue
+					}
+
+					group.Targets = append(group.Targets, endpointTarget(address.IP, port.Port, pod))
+				}
+			}
+		}
+
+		groups = append(groups, group)
+	}
+
+	return groups
+}
+
+// endpointTarget returns the target of the address of the endpoints.
+// If the address belongs to a pod, the labels of the pod are added.
+func endpointTarget(ip string, port int32, pod *corev1.Pod) model.LabelSet {
+	target := model.LabelSet{
+		discovery.AddressLabel: model.LabelValue(net.JoinHostPort(ip, strconv.Itoa(int(port)))),
+	}
+
+	if pod == nil {
+		return target
+	}
+
+	target = podLabels(pod).Merge(target)
+
+	if container, portName := containerOfPort(pod, port); container != "" {
+		target[podContainerNameMetaLabel] = model.LabelValue(container)
+		target[ContainerLabel] = model.LabelValue(container)
+
+		if portName != "" {
+			target[podContainerPortNameMetaLabel] = model.LabelValue(portName)
+		}
+	}
+
+	return target
+}
+
+// annotatedPort returns the port in the PortAnnotation.
+func annotatedPort(annotations map[string]string) (int32, bool) {
+	port, err := strconv.ParseInt(annotations[PortAnnotation], 10, 32)
+	if err != nil || port <= 0 {
+		return 0, false
+	}
+
+	return int32(port), true
+}
+
+// containerOfPort returns the name of the container declaring the port, and the name of the port.
+func containerOfPort(pod *corev1.Pod, port int32) (string, string) {
+	for _, container := range pod.Spec.Containers {
+		for _, p := range container.Ports {
+			if p.ContainerPort == port {
+				return container.Name, p.Name
+			}
+		}
+	}
+
+	return "", ""
+}
+
+// podLabels returns the labels of the pod.
+func podLabels(pod *corev1.Pod) model.LabelSet {
+	ls := model.LabelSet{
+		NamespaceLabel:       model.LabelValue(pod.Namespace),
+		PodLabel:             model.LabelValue(pod.Name),
+		namespaceMetaLabel:   model.LabelValue(pod.Namespace),
+		podNameMetaLabel:     model.LabelValue(pod.Name),
+		podIPMetaLabel:       model.LabelValue(pod.Status.PodIP),
+		podNodeNameMetaLabel: model.LabelValue(pod.Spec.NodeName),
+	}
+
+	addObjectLabels(ls, podLabelMetaLabelPrefix, pod.Labels)
+	addObjectLabels(ls, podAnnotationMetaLabelPrefix, pod.Annotations)
+
+	return ls
+}
+
+// serviceLabels returns the labels of the service.
+func serviceLabels(service *corev1.Service) model.LabelSet {
+	ls := model.LabelSet{
+		NamespaceLabel:       model.LabelValue(service.Namespace),
+		namespaceMetaLabel:   model.LabelValue(service.Namespace),
+		serviceNameMetaLabel: model.LabelValue(service.Name),
+	}
+
+	addObjectLabels(ls, serviceLabelMetaLabelPrefix, service.Labels)
+	addObjectLabels(ls, serviceAnnotationMetaLabelPrefix, service.Annotations)
+	addAnnotatedURL(ls, service.Annotations)
+
+	return ls
+}
+
+// addObjectLabels adds the labels or annotations of an object as the meta labels with the prefix.
+func addObjectLabels(ls model.LabelSet, prefix string, objectLabels map[string]string) {
+	for name, value := range objectLabels {
+		ls[model.LabelName(prefix+discovery.SanitizeLabelName(name))] = model.LabelValue(value)
+	}
+}
+
+// addAnnotatedURL sets the scheme and the path in the annotations.
+func addAnnotatedURL(ls model.LabelSet, annotations map[string]string) {
+	if scheme := annotations[SchemeAnnotation]; scheme != "" {
+		ls[discovery.SchemeLabel] = model.LabelValue(strings.ToLower(scheme))
+	}
+
+	if path := annotations[PathAnnotation]; path != "" {
+		ls[discovery.MetricsPathLabel] = model.LabelValue(path)
+	}
+}
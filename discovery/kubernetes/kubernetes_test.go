@@ -0,0 +1,196 @@
+package kubernetes_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/d-kuro/promaggr/discovery"
+	"github.com/d-kuro/promaggr/discovery/kubernetes"
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/common/model"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestDiscovererPod(t *testing.T) {
+	t.Parallel()
+
+	client := fake.NewSimpleClientset(
+		newPod("default", "node-exporter", "10.0.0.1", map[string]string{
+			"prometheus.io/scrape": "true",
+			"prometheus.io/port":   "9100",
+			"prometheus.io/path":   "/node/metrics",
+		}),
+		newPod("default", "not-annotated", "10.0.0.2", nil),
+		newPod("kube-system", "other-namespace", "10.0.0.3", map[string]string{"prometheus.io/scrape": "true"}),
+	)
+
+	discoverer := kubernetes.NewDiscoverer(client, kubernetes.RolePod,
+		kubernetes.Namespaces("default"),
+		kubernetes.LabelSelector("app=exporter"),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch := make(chan []*discovery.TargetGroup)
+	go discoverer.Run(ctx, ch)
+
+	want := []*discovery.TargetGroup{
+		{
+			Targets: []model.LabelSet{
+				{
+					"__address__":                               "10.0.0.1:9100",
+					"__meta_kubernetes_pod_container_name":      "exporter",
+					"__meta_kubernetes_pod_container_port_name": "metrics",
+					"container": "exporter",
+				},
+			},
+			Labels: model.LabelSet{
+				"__meta_kubernetes_namespace":                           "default",
+				"__meta_kubernetes_pod_name":                            "node-exporter",
+				"__meta_kubernetes_pod_ip":                              "10.0.0.1",
+				"__meta_kubernetes_pod_node_name":                       "node1",
+				"__meta_kubernetes_pod_label_app":                       "exporter",
+				"__meta_kubernetes_pod_annotation_prometheus_io_scrape": "true",
+				"__meta_kubernetes_pod_annotation_prometheus_io_port":   "9100",
+				"__meta_kubernetes_pod_annotation_prometheus_io_path":   "/node/metrics",
+				"__metrics_path__":                                      "/node/metrics",
+				"namespace":                                             "default",
+				"pod":                                                   "node-exporter",
+			},
+			Source: "pod/default/node-exporter",
+		},
+	}
+
+	if diff := cmp.Diff(want, receive(t, ch)); diff != "" {
+		t.Errorf("target groups mismatch (-want +got):\n%s", diff)
+	}
+
+	// The pods are watched.
+	if err := client.CoreV1().Pods("default").Delete(ctx, "node-exporter", metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("failed to delete pod: %v", err)
+	}
+
+	waitForGroups(t, ch, 0)
+}
+
+func TestDiscovererEndpoints(t *testing.T) {
+	t.Parallel()
+
+	pod := newPod("default", "api-0", "10.0.0.1", nil)
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "default",
+			Name:        "api",
+			Labels:      map[string]string{"app": "exporter"},
+			Annotations: map[string]string{"prometheus.io/scrape": "true", "prometheus.io/port": "9100"},
+		},
+	}
+	endpoints := &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "api"},
+		Subsets: []corev1.EndpointSubset{
+			{
+				Addresses: []corev1.EndpointAddress{
+					{IP: "10.0.0.1", TargetRef: &corev1.ObjectReference{Kind: "Pod", Namespace: "default", Name: "api-0"}},
+				},
+				Ports: []corev1.EndpointPort{
+					{Name: "http", Port: 8080, Protocol: corev1.ProtocolTCP},
+					{Name: "metrics", Port: 9100, Protocol: corev1.ProtocolTCP},
+				},
+			},
+		},
+	}
+
+	client := fake.NewSimpleClientset(pod, service, endpoints)
+	discoverer := kubernetes.NewDiscoverer(client, kubernetes.RoleEndpoints)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch := make(chan []*discovery.TargetGroup)
+	go discoverer.Run(ctx, ch)
+
+	groups := receive(t, ch)
+	if len(groups) != 1 {
+		t.Fatalf("number of target groups mismatch: want(1) got(%d)", len(groups))
+	}
+
+	if got := groups[0].Labels["__meta_kubernetes_service_name"]; got != "api" {
+		t.Errorf("service name mismatch: want(api) got(%s)", got)
+	}
+
+	if len(groups[0].Targets) != 1 {
+		t.Fatalf("number of targets mismatch: want(1) got(%d)", len(groups[0].Targets))
+	}
+
+	target := groups[0].Targets[0]
+	wantLabels := model.LabelSet{
+		"__address__": "10.0.0.1:9100",
+		"namespace":   "default",
+		"pod":         "api-0",
+		"container":   "exporter",
+	}
+
+	for name, want := range wantLabels {
+		if got := target[name]; got != want {
+			t.Errorf("label %s mismatch: want(%s) got(%s)", name, want, got)
+		}
+	}
+}
+
+func newPod(namespace, name, ip string, annotations map[string]string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   namespace,
+			Name:        name,
+			Labels:      map[string]string{"app": "exporter"},
+			Annotations: annotations,
+		},
+		Spec: corev1.PodSpec{
+			NodeName: "node1",
+			Containers: []corev1.Container{
+				{
+					Name:  "exporter",
+					Ports: []corev1.ContainerPort{{Name: "metrics", ContainerPort: 9100, Protocol: corev1.ProtocolTCP}},
+				},
+			},
+		},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodRunning,
+			PodIP: ip,
+		},
+	}
+}
+
+func receive(t *testing.T, ch <-chan []*discovery.TargetGroup) []*discovery.TargetGroup {
+	t.Helper()
+
+	select {
+	case groups := <-ch:
+		return groups
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for target groups")
+
+		return nil
+	}
+}
+
+func waitForGroups(t *testing.T, ch <-chan []*discovery.TargetGroup, n int) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+
+	for {
+		groups := receive(t, ch)
+		if len(groups) == n {
+			return
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("number of target groups mismatch: want(%d) got(%d)", n, len(groups))
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package promaggr
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+)
+
+var _ Source = &ExecSource{}
+
+// ExecError is returned when the command of the ExecSource fails.
+type ExecError struct {
+	// Command is the command that failed.
+	Command string
+
+	// ExitCode is the exit code of the command.
+	// It is -1 if the command did not exit normally, e.g. it failed to start or was killed by the timeout.
+	ExitCode int
+
+	// Stderr is the standard error output of the command.
+	Stderr string
+
+	// Err is the underlying error.
+	Err error
+}
+
+// Error implements the error interface.
+func (e *ExecError) Error() string {
+	if e.Stderr == "" {
+		return fmt.Sprintf("command %s failed with exit code %d: %v", e.Command, e.ExitCode, e.Err)
+	}
+
+	return fmt.Sprintf("command %s failed with exit code %d: %v: %s", e.Command, e.ExitCode, e.Err, e.Stderr)
+}
+
+// Unwrap returns the underlying error.
+func (e *ExecError) Unwrap() error {
+	return e.Err
+}
+
+// ExecSourceOption is a functional option used by the NewExecSource.
+type ExecSourceOption func(*ExecSource)
+
+// ExecSource is a Source that runs a command and parses its standard output in the text format.
+// It is useful for legacy tools that print metrics to the standard output.
+type ExecSource struct {
+	// Command is the name or the path of the command to run.
+	Command string
+
+	// Args is the arguments of the command.
+	Args []string
+
+	// Env is the environment of the command, in the form of "key=value".
+	// If not specified, the command inherits the environment of the current process.
+	Env []string
+
+	// Timeout is the time limit for the command to finish. If exceeded, the command will be killed.
+	// If 0, there is no limit other than the context.
+	Timeout time.Duration
+
+	// Labels is a set of labels to be added to the metrics.
+	// If not specified, nothing will be added.
+	Labels model.LabelSet
+
+	// HonorLabels controls how conflicts between the printed labels and Labels are resolved.
+	// It is the same as the HonorLabels of the Scraper.
+	HonorLabels bool
+
+	// ExportConflictingLabels keeps the printed labels overridden by Labels as "exported_<label name>".
+	// It is the same as the ExportConflictingLabels of the Scraper.
+	ExportConflictingLabels bool
+}
+
+// NewExecSource creates and returns a new ExecSource.
+func NewExecSource(command string, opts ...ExecSourceOption) *ExecSource {
+	source := &ExecSource{
+		Command: command,
+	}
+
+	for _, o := range opts {
+		o(source)
+	}
+
+	return source
+}
+
+// ExecSourceArgs is an option available for NewExecSource.
+// The command will be run with the given arguments.
+func ExecSourceArgs(args ...string) ExecSourceOption {
+	return func(s *ExecSource) {
+		s.Args = args
+	}
+}
+
+// ExecSourceEnv is an option available for NewExecSource.
+// The command will be run with the given environment, in the form of "key=value".
+func ExecSourceEnv(env ...string) ExecSourceOption {
+	return func(s *ExecSource) {
+		s.Env = env
+	}
+}
+
+// ExecSourceTimeout is an option available for NewExecSource.
+// The command will be killed if it does not finish within the timeout.
+func ExecSourceTimeout(timeout time.Duration) ExecSourceOption {
+	return func(s *ExecSource) {
+		s.Timeout = timeout
+	}
+}
+
+// ExecSourceLabels is an option available for NewExecSource.
+// This will be used to add labels to the metrics.
+func ExecSourceLabels(labelSets model.LabelSet) ExecSourceOption {
+	return func(s *ExecSource) {
+		s.Labels = labelSets
+	}
+}
+
+// ExecSourceHonorLabels is an option available for NewExecSource.
+// If true, the printed labels take precedence over the labels set by the ExecSourceLabels option.
+func ExecSourceHonorLabels(honor bool) ExecSourceOption {
+	return func(s *ExecSource) {
+		s.HonorLabels = honor
+	}
+}
+
+// ExecSourceExportConflictingLabels is an option available for NewExecSource.
+// If true, the printed labels overridden by the labels set by the ExecSourceLabels option
+// are kept as "exported_<label name>".
+func ExecSourceExportConflictingLabels(export bool) ExecSourceOption {
+	return func(s *ExecSource) {
+		s.ExportConflictingLabels = export
+	}
+}
+
+// Fetch implements the Source interface.
+// It runs the command and parses its standard output.
+// If the command fails, an ExecError with the exit code and the standard error output is returned.
+func (s *ExecSource) Fetch(ctx context.Context) ([]*dto.MetricFamily, error) {
+	if s.Timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, s.Timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, s.Command, s.Args...) //nolint:gosec // The command is configured by the user.
+	cmd.Env = s.Env
+
+	stdout, stderr, err := runCommand(ctx, cmd)
+	if err != nil {
+		execErr := &ExecError{
+			Command:  s.Command,
+			ExitCode: -1,
+			Stderr:   strings.TrimSpace(stderr.String()),
+			Err:      err,
+		}
+
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			execErr.ExitCode = exitErr.ExitCode()
+		}
+
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			execErr.Err = fmt.Errorf("%w: %v", ctxErr, err)
+		}
+
+		return nil, execErr
+	}
+
+	mfs, err := decodeMetricFamilies(NewDecoder(stdout, expfmt.FmtText), func(mf *dto.MetricFamily) error {
+		dropTimestamps(mf)
+
+		if s.Labels != nil {
+			addTargetLabels([]*dto.MetricFamily{mf}, s.Labels, s.HonorLabels, s.ExportConflictingLabels)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the output of command %s: %w", s.Command, err)
+	}
+
+	return mfs, nil
+}
+
+// runCommand runs the command and returns its standard output and standard error output.
+// Unlike exec.Cmd.Run, it does not wait for the output to be closed after the context is done,
+// because it may be kept open by the child processes of the killed command.
+func runCommand(ctx context.Context, cmd *exec.Cmd) (stdout, stderr *bytes.Buffer, err error) {
+	stdout, stderr = &bytes.Buffer{}, &bytes.Buffer{}
+
+	stdoutPipe, err := cmd.StdoutPipe()
+	if err != nil {
+		return stdout, stderr, fmt.Errorf("failed to create stdout pipe: %w", err)
+	}
+
+	stderrPipe, err := cmd.StderrPipe()
+	if err != nil {
+		return stdout, stderr, fmt.Errorf("failed to create stderr pipe: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return stdout, stderr, fmt.Errorf("failed to start: %w", err)
+	}
+
+	var wg sync.WaitGroup
+
+	done := make(chan struct{})
+
+	for _, c := range []struct {
+		w io.Writer
+		r io.Reader
+	}{{stdout, stdoutPipe}, {stderr, stderrPipe}} {
+		c := c
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			_, _ = io.Copy(c.w, c.r)
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	// Wait closes the pipes, so the output has to be read before it is called unless the context is done.
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+
+	err = cmd.Wait()
+
+	<-done
+
+	if err != nil {
+		return stdout, stderr, fmt.Errorf("failed to run: %w", err)
+	}
+
+	return stdout, stderr, nil
+}
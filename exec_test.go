@@ -0,0 +1,100 @@
+package promaggr_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/d-kuro/promaggr"
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+)
+
+func TestExecSource(t *testing.T) {
+	t.Parallel()
+
+	source := promaggr.NewExecSource("sh",
+		promaggr.ExecSourceArgs("-c", `printf '# TYPE legacy_metric gauge\nlegacy_metric{name="%s"} 1\n' "$NAME"`),
+		promaggr.ExecSourceEnv("NAME=foo"),
+		promaggr.ExecSourceTimeout(10*time.Second),
+		promaggr.ExecSourceLabels(model.LabelSet{"tool": "legacy"}),
+	)
+
+	mfs, err := source.Fetch(context.Background())
+	if err != nil {
+		t.Fatalf("failed to fetch: %v", err)
+	}
+
+	out := bytes.Buffer{}
+
+	for _, mf := range mfs {
+		if _, err := expfmt.MetricFamilyToText(&out, mf); err != nil {
+			t.Fatalf("failed to convert MetricFamily to text: %v", err)
+		}
+	}
+
+	want := `# TYPE legacy_metric gauge
+legacy_metric{name="foo",tool="legacy"} 1
+`
+
+	if diff := cmp.Diff(want, out.String()); diff != "" {
+		t.Errorf("exec source metrics mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestExecSourceError(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		source       *promaggr.ExecSource
+		wantExitCode int
+		wantStderr   string
+		wantErr      error
+	}{
+		{
+			name:         "exit code",
+			source:       promaggr.NewExecSource("sh", promaggr.ExecSourceArgs("-c", "echo 'something went wrong' >&2; exit 3")),
+			wantExitCode: 3,
+			wantStderr:   "something went wrong",
+		},
+		{
+			name: "timeout",
+			source: promaggr.NewExecSource("sh",
+				promaggr.ExecSourceArgs("-c", "sleep 10"),
+				promaggr.ExecSourceTimeout(100*time.Millisecond),
+			),
+			wantExitCode: -1,
+			wantErr:      context.DeadlineExceeded,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := tt.source.Fetch(context.Background())
+
+			var execErr *promaggr.ExecError
+			if !errors.As(err, &execErr) {
+				t.Fatalf("error is not an ExecError: %v", err)
+			}
+
+			if execErr.ExitCode != tt.wantExitCode {
+				t.Errorf("exit code mismatch: want(%d) got(%d)", tt.wantExitCode, execErr.ExitCode)
+			}
+
+			if execErr.Stderr != tt.wantStderr {
+				t.Errorf("stderr mismatch: want(%s) got(%s)", tt.wantStderr, execErr.Stderr)
+			}
+
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("unexpected error: want(%v) got(%v)", tt.wantErr, err)
+			}
+		})
+	}
+}
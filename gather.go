@@ -0,0 +1,213 @@
+package promaggr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/model"
+)
+
+var _ prometheus.Gatherer = &Collector{}
+
+var (
+	// ErrInvalidMetric is returned by Gather for a metric that is not consistent with its MetricFamily.
+	ErrInvalidMetric = errors.New("invalid metric")
+
+	// ErrDuplicateMetric is returned by Gather for a metric that has the same name and labels as another metric.
+	ErrDuplicateMetric = errors.New("duplicate metric")
+)
+
+// Gather implements the prometheus.Gatherer interface.
+// Unlike Collect, the merged MetricFamily's are returned directly,
+// without converting them to prometheus.Metric and back to MetricFamily through a prometheus.Registry.
+// It can be combined with other registries by using prometheus.Gatherers.
+//
+// As with prometheus.Registry, the MetricFamily's are sorted by name and the metrics are sorted by labels.
+// Metrics that are inconsistent with their MetricFamily or duplicated are dropped,
+// and reported as a prometheus.MultiError along with the rest of the MetricFamily's.
+func (c *Collector) Gather() ([]*dto.MetricFamily, error) {
+	c.rsyncCache(context.Background())
+
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return normalizeMetricFamilies(c.cache)
+}
+
+// normalizeMetricFamilies checks the consistency of the MetricFamily's and returns sorted copies of them.
+// The given MetricFamily's are not modified.
+func normalizeMetricFamilies(mfs []*dto.MetricFamily) ([]*dto.MetricFamily, error) {
+	var errs prometheus.MultiError
+
+	normalized := make([]*dto.MetricFamily, 0, len(mfs))
+	seen := make(map[string]struct{})
+
+	for _, mf := range mfs {
+		if !model.IsValidMetricName(model.LabelValue(mf.GetName())) {
+			errs = append(errs, fmt.Errorf("%w: invalid metric name %q", ErrInvalidMetric, mf.GetName()))
+
+			continue
+		}
+
+		out := &dto.MetricFamily{
+			Name:   mf.Name,
+			Help:   mf.Help,
+			Type:   mf.Type,
+			Metric: make([]*dto.Metric, 0, len(mf.GetMetric())),
+		}
+
+		for _, m := range mf.GetMetric() {
+			m = copyMetric(m)
+
+			if err := checkMetricConsistency(mf, m); err != nil {
+				errs = append(errs, err)
+
+				continue
+			}
+
+			id := mf.GetName() + labelPairsString(m.GetLabel())
+			if _, ok := seen[id]; ok {
+				errs = append(errs, fmt.Errorf("%w: %s", ErrDuplicateMetric, id))
+
+				continue
+			}
+
+			seen[id] = struct{}{}
+
+			out.Metric = append(out.Metric, m)
+		}
+
+		if len(out.Metric) == 0 {
+			continue
+		}
+
+		sortMetrics(out.Metric)
+		normalized = append(normalized, out)
+	}
+
+	sortMetricFamilies(normalized)
+
+	return normalized, errs.MaybeUnwrap()
+}
+
+// copyMetric returns a shallow copy of the metric with the labels sorted by label name.
+func copyMetric(m *dto.Metric) *dto.Metric {
+	labels := make([]*dto.LabelPair, len(m.GetLabel()))
+	copy(labels, m.GetLabel())
+	sortLabelPairs(labels)
+
+	return &dto.Metric{
+		Label:       labels,
+		Gauge:       m.Gauge,
+		Counter:     m.Counter,
+		Summary:     m.Summary,
+		Untyped:     m.Untyped,
+		Histogram:   m.Histogram,
+		TimestampMs: m.TimestampMs,
+	}
+}
+
+// checkMetricConsistency checks that the metric is consistent with its MetricFamily,
+// in the same way as prometheus.Registry.
+// The labels of the metric must be sorted by label name.
+func checkMetricConsistency(mf *dto.MetricFamily, m *dto.Metric) error {
+	name := mf.GetName()
+
+	var valueMismatch bool
+
+	switch mf.GetType() {
+	case dto.MetricType_COUNTER:
+		valueMismatch = m.Counter == nil
+	case dto.MetricType_GAUGE:
+		valueMismatch = m.Gauge == nil
+	case dto.MetricType_UNTYPED:
+		valueMismatch = m.Untyped == nil
+	case dto.MetricType_SUMMARY:
+		valueMismatch = m.Summary == nil
+	case dto.MetricType_HISTOGRAM:
+		valueMismatch = m.Histogram == nil
+	default:
+		valueMismatch = true
+	}
+
+	if valueMismatch {
+		return fmt.Errorf("%w: metric %s%s is not a %s", ErrInvalidMetric, name, labelPairsString(m.GetLabel()), mf.GetType())
+	}
+
+	for i, l := range m.GetLabel() {
+		labelName := l.GetName()
+
+		if !model.LabelName(labelName).IsValid() || strings.HasPrefix(labelName, model.ReservedLabelPrefix) {
+			return fmt.Errorf("%w: metric %s has an invalid label name %q", ErrInvalidMetric, name, labelName)
+		}
+
+		if !utf8.ValidString(l.GetValue()) {
+			return fmt.Errorf("%w: metric %s has an invalid label value %q", ErrInvalidMetric, name, l.GetValue())
+		}
+
+		if i > 0 && m.GetLabel()[i-1].GetName() == labelName {
+			return fmt.Errorf("%w: metric %s has a duplicate label name %q", ErrInvalidMetric, name, labelName)
+		}
+
+		if (mf.GetType() == dto.MetricType_SUMMARY && labelName == model.QuantileLabel) ||
+			(mf.GetType() == dto.MetricType_HISTOGRAM && labelName == model.BucketLabel) {
+			return fmt.Errorf("%w: metric %s has a reserved label name %q", ErrInvalidMetric, name, labelName)
+		}
+	}
+
+	return nil
+}
+
+// sortLabelPairs sorts the labels by label name.
+func sortLabelPairs(labels []*dto.LabelPair) {
+	sort.Slice(labels, func(i, j int) bool {
+		return labels[i].GetName() < labels[j].GetName()
+	})
+}
+
+// sortMetricFamilies sorts the MetricFamily's by name.
+func sortMetricFamilies(mfs []*dto.MetricFamily) {
+	sort.Slice(mfs, func(i, j int) bool {
+		return mfs[i].GetName() < mfs[j].GetName()
+	})
+}
+
+// sortMetrics sorts the metrics by labels, and then by timestamp, in the same way as prometheus.Registry.
+// The labels of each metric must be sorted by label name.
+func sortMetrics(metrics []*dto.Metric) {
+	sort.SliceStable(metrics, func(i, j int) bool {
+		li, lj := metrics[i].GetLabel(), metrics[j].GetLabel()
+
+		for n := 0; n < len(li) && n < len(lj); n++ {
+			if li[n].GetName() != lj[n].GetName() {
+				return li[n].GetName() < lj[n].GetName()
+			}
+
+			if li[n].GetValue() != lj[n].GetValue() {
+				return li[n].GetValue() < lj[n].GetValue()
+			}
+		}
+
+		if len(li) != len(lj) {
+			return len(li) < len(lj)
+		}
+
+		return metrics[i].GetTimestampMs() < metrics[j].GetTimestampMs()
+	})
+}
+
+// labelPairsString returns the string representation of the labels, such as {foo="bar"}.
+func labelPairsString(labels []*dto.LabelPair) string {
+	pairs := make([]string, 0, len(labels))
+	for _, l := range labels {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", l.GetName(), l.GetValue()))
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
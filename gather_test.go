@@ -0,0 +1,98 @@
+package promaggr_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/d-kuro/promaggr"
+	"github.com/d-kuro/promaggr/internal"
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+func TestCollectorGather(t *testing.T) {
+	t.Parallel()
+
+	invalid := internal.NewGaugeMetricFamilyFixture("dummy_counter_metric",
+		internal.Label([]*dto.LabelPair{
+			{Name: internal.StringToPointer("name"), Value: internal.StringToPointer("invalid")},
+		}),
+	)
+	invalid.Type = dto.MetricType_COUNTER.Enum()
+
+	source := promaggr.SourceFunc(func(ctx context.Context) ([]*dto.MetricFamily, error) {
+		return []*dto.MetricFamily{
+			internal.NewGaugeMetricFamilyFixture("dummy_gauge_metric",
+				internal.Label([]*dto.LabelPair{
+					{Name: internal.StringToPointer("name"), Value: internal.StringToPointer("foo")},
+				}),
+			),
+			internal.NewCounterMetricFamilyFixture("dummy_counter_metric",
+				internal.Label([]*dto.LabelPair{
+					{Name: internal.StringToPointer("name"), Value: internal.StringToPointer("foo")},
+				}),
+			),
+			internal.NewCounterMetricFamilyFixture("dummy_counter_metric",
+				internal.Label([]*dto.LabelPair{
+					{Name: internal.StringToPointer("name"), Value: internal.StringToPointer("bar")},
+				}),
+			),
+			// The same metric as above, which is dropped while merging.
+			internal.NewCounterMetricFamilyFixture("dummy_counter_metric",
+				internal.Label([]*dto.LabelPair{
+					{Name: internal.StringToPointer("name"), Value: internal.StringToPointer("bar")},
+				}),
+			),
+			invalid,
+		}, nil
+	})
+
+	counter := newHTTPRequestCounter()
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(counter)
+	counter.WithLabelValues("200", http.MethodGet).Inc()
+
+	collector := promaggr.NewCollector(nil, promaggr.Sources(source))
+
+	_, err := collector.Gather()
+
+	if !errors.Is(err, promaggr.ErrInvalidMetric) {
+		t.Fatalf("invalid metric is not reported: %v", err)
+	}
+
+	// Gatherers reports the errors of the Collector, and returns the rest of the metrics.
+	mfs, err := prometheus.Gatherers{registry, collector}.Gather()
+	if err == nil {
+		t.Errorf("errors of the Collector are not reported by Gatherers")
+	}
+
+	out := bytes.Buffer{}
+
+	for _, mf := range mfs {
+		if _, err := expfmt.MetricFamilyToText(&out, mf); err != nil {
+			t.Fatalf("failed to convert MetricFamily to text: %v", err)
+		}
+	}
+
+	got := out.String()
+	want := `# HELP dummy_counter_metric Dummy text.
+# TYPE dummy_counter_metric counter
+dummy_counter_metric{name="bar"} 123456
+dummy_counter_metric{name="foo"} 123456
+# HELP dummy_gauge_metric Dummy text.
+# TYPE dummy_gauge_metric gauge
+dummy_gauge_metric{name="foo"} 123.456
+# HELP http_requests_total Dummy text.
+# TYPE http_requests_total counter
+http_requests_total{code="200",method="GET"} 1
+`
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("gathered metrics mismatch (-want +got):\n%s", diff)
+	}
+}
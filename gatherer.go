@@ -0,0 +1,128 @@
+package promaggr
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/model"
+)
+
+var _ Source = &GathererSource{}
+
+// GathererSourceOption is a functional option used by the NewGathererSource.
+type GathererSourceOption func(*GathererSource)
+
+// GathererSource is a Source that gathers metrics from an in-process prometheus.Gatherer, such as prometheus.Registry.
+// The metrics are gathered directly without any HTTP round trip.
+// Labels are added to the gathered metrics in the same way as the Scraper, but they are not relabeled.
+// Do not use the prometheus.Gatherer to which the Collector aggregating this source is registered,
+// as gathering it would recursively gather this source.
+type GathererSource struct {
+	// Name is the identifier of the source, which is used to merge and to report its results.
+	// If not specified, the identifier is the type name of the source,
+	// which is suffixed according to the order of the sources if there are several.
+	Name string
+
+	// Gatherer is the prometheus.Gatherer to gather metrics from.
+	Gatherer prometheus.Gatherer
+
+	// Labels is a set of labels to be added to the gathered metrics.
+	// If not specified, nothing will be added.
+	Labels model.LabelSet
+
+	// HonorLabels controls how conflicts between the gathered labels and Labels are resolved.
+	// It is the same as the HonorLabels of the Scraper.
+	HonorLabels bool
+
+	// ExportConflictingLabels keeps the gathered labels overridden by Labels as "exported_<label name>".
+	// It is the same as the ExportConflictingLabels of the Scraper.
+	ExportConflictingLabels bool
+
+	// Logger is a logger that implements the logr.Logger interface.
+	// If it is not specified, nothing will be logged.
+	Logger logr.Logger
+}
+
+// NewGathererSource creates and returns a new GathererSource.
+func NewGathererSource(gatherer prometheus.Gatherer, opts ...GathererSourceOption) *GathererSource {
+	source := &GathererSource{
+		Gatherer: gatherer,
+	}
+
+	for _, o := range opts {
+		o(source)
+	}
+
+	return source
+}
+
+// GathererSourceName is an option available for NewGathererSource.
+// This will be used to identify the source among the sources of the Collector.
+func GathererSourceName(name string) GathererSourceOption {
+	return func(s *GathererSource) {
+		s.Name = name
+	}
+}
+
+// GathererSourceLabels is an option available for NewGathererSource.
+// This will be used to add labels to the gathered metrics.
+func GathererSourceLabels(labelSets model.LabelSet) GathererSourceOption {
+	return func(s *GathererSource) {
+		s.Labels = labelSets
+	}
+}
+
+// GathererSourceHonorLabels is an option available for NewGathererSource.
+// If true, the gathered labels take precedence over the labels set by the GathererSourceLabels option.
+func GathererSourceHonorLabels(honor bool) GathererSourceOption {
+	return func(s *GathererSource) {
+		s.HonorLabels = honor
+	}
+}
+
+// GathererSourceExportConflictingLabels is an option available for NewGathererSource.
+// If true, the gathered labels overridden by the labels set by the GathererSourceLabels option
+// are kept as "exported_<label name>".
+func GathererSourceExportConflictingLabels(export bool) GathererSourceOption {
+	return func(s *GathererSource) {
+		s.ExportConflictingLabels = export
+	}
+}
+
+// GathererSourceLogger is an option available for NewGathererSource.
+// If a logger is set, the errors returned along with the gathered metrics will be output to the log.
+func GathererSourceLogger(logger logr.Logger) GathererSourceOption {
+	return func(s *GathererSource) {
+		s.Logger = logger
+	}
+}
+
+// Fetch implements the Source interface.
+// It gathers metrics from the prometheus.Gatherer and adds the labels to them.
+// As with promhttp.ContinueOnError, if the prometheus.Gatherer returns an error along with some metrics,
+// the metrics are kept and the error is logged. An error is returned only if no metrics are gathered.
+func (s *GathererSource) Fetch(ctx context.Context) ([]*dto.MetricFamily, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("failed to gather metrics: %w", err)
+	}
+
+	mfs, err := s.Gatherer.Gather()
+	if err != nil {
+		if len(mfs) == 0 {
+			return nil, fmt.Errorf("failed to gather metrics: %w", err)
+		}
+
+		if s.Logger != nil {
+			s.Logger.Error(err, "failed to gather some metrics")
+		}
+	}
+
+	if s.Labels != nil {
+		addTargetLabels(mfs, s.Labels, s.HonorLabels, s.ExportConflictingLabels)
+	}
+
+	return mfs, nil
+}
@@ -0,0 +1,132 @@
+package promaggr_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/d-kuro/promaggr"
+	"github.com/d-kuro/promaggr/internal"
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+)
+
+func TestGathererSource(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name              string
+		honorLabels       bool
+		exportConflicting bool
+		want              string
+	}{
+		{
+			name: "default",
+			want: `# HELP http_requests_total Dummy text.
+# TYPE http_requests_total counter
+http_requests_total{code="500",method="GET"} 1
+`,
+		},
+		{
+			name:        "honor labels",
+			honorLabels: true,
+			want: `# HELP http_requests_total Dummy text.
+# TYPE http_requests_total counter
+http_requests_total{code="200",method="GET"} 1
+`,
+		},
+		{
+			name:              "do not honor labels",
+			honorLabels:       false,
+			exportConflicting: true,
+			want: `# HELP http_requests_total Dummy text.
+# TYPE http_requests_total counter
+http_requests_total{code="500",exported_code="200",method="GET"} 1
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			counter := newHTTPRequestCounter()
+			registry := prometheus.NewRegistry()
+			registry.MustRegister(counter)
+			counter.WithLabelValues("200", http.MethodGet).Inc()
+
+			source := promaggr.NewGathererSource(registry,
+				promaggr.GathererSourceLabels(model.LabelSet{"code": "500"}),
+				promaggr.GathererSourceHonorLabels(tt.honorLabels),
+				promaggr.GathererSourceExportConflictingLabels(tt.exportConflicting),
+			)
+
+			mfs, err := source.Fetch(context.Background())
+			if err != nil {
+				t.Fatalf("failed to fetch: %v", err)
+			}
+
+			out := bytes.Buffer{}
+
+			for _, mf := range mfs {
+				if _, err := expfmt.MetricFamilyToText(&out, mf); err != nil {
+					t.Fatalf("failed to convert MetricFamily to text: %v", err)
+				}
+			}
+
+			if diff := cmp.Diff(tt.want, out.String()); diff != "" {
+				t.Errorf("gathered metrics mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGathererSourcePartialResults(t *testing.T) {
+	t.Parallel()
+
+	errPartial := errors.New("partial failure")
+
+	tests := []struct {
+		name     string
+		gatherer prometheus.Gatherer
+		wantLen  int
+		wantErr  error
+	}{
+		{
+			name: "partial results",
+			gatherer: prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+				return []*dto.MetricFamily{internal.NewCounterMetricFamilyFixture("ok_total")}, errPartial
+			}),
+			wantLen: 1,
+		},
+		{
+			name: "no results",
+			gatherer: prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+				return nil, errPartial
+			}),
+			wantErr: errPartial,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mfs, err := promaggr.NewGathererSource(tt.gatherer).Fetch(context.Background())
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("error mismatch: want(%v) got(%v)", tt.wantErr, err)
+			}
+
+			if len(mfs) != tt.wantLen {
+				t.Errorf("number of MetricFamily's mismatch: want(%d) got(%d)", tt.wantLen, len(mfs))
+			}
+		})
+	}
+}
@@ -4,8 +4,12 @@ go 1.16
 
 require (
 	github.com/go-logr/logr v0.4.0
+	github.com/go-logr/stdr v0.4.0
 	github.com/google/go-cmp v0.5.6
+	github.com/klauspost/compress v1.13.6
 	github.com/prometheus/client_golang v1.11.0
 	github.com/prometheus/client_model v0.2.0
 	github.com/prometheus/common v0.29.0
+	google.golang.org/protobuf v1.26.0
+	gopkg.in/yaml.v2 v2.4.0
 )
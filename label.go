@@ -42,3 +42,77 @@ func AddLabels(mfs []*dto.MetricFamily, labels model.LabelSet) {
 		}
 	}
 }
+
+// addTargetLabels adds the given target labels to all metrics in the given MetricFamily's.
+// Conflicts between the scraped labels and the target labels are resolved
+// in the same way as the honor_labels setting of Prometheus.
+// If honorLabels is true, the scraped labels are kept.
+// If honorLabels is false, the conflicting scraped labels are overridden by the target labels,
+// and they are kept as "exported_<label name>" if exportConflicting is true.
+func addTargetLabels(mfs []*dto.MetricFamily, labels model.LabelSet, honorLabels, exportConflicting bool) {
+	for _, mf := range mfs {
+		for _, m := range mf.Metric {
+			addTargetLabelsToMetric(m, labels, honorLabels, exportConflicting)
+		}
+	}
+}
+
+// addTargetLabelsToMetric adds the given target labels to the metric in the same way as addTargetLabels.
+func addTargetLabelsToMetric(m *dto.Metric, labels model.LabelSet, honorLabels, exportConflicting bool) {
+	outputSet := make(model.LabelSet, len(m.Label)+len(labels))
+
+	for _, l := range m.GetLabel() {
+		if l.Name != nil {
+			outputSet[model.LabelName(l.GetName())] = model.LabelValue(l.GetValue())
+		}
+	}
+
+	for name, value := range labels {
+		scrapedValue, ok := outputSet[name]
+
+		switch {
+		case !ok:
+			outputSet[name] = value
+		case honorLabels:
+			continue
+		case !exportConflicting:
+			outputSet[name] = value
+		default:
+			exportedName := model.LabelName(model.ExportedLabelPrefix + name)
+			for {
+				if _, ok := outputSet[exportedName]; !ok {
+					break
+				}
+
+				exportedName = model.ExportedLabelPrefix + exportedName
+			}
+
+			outputSet[exportedName] = scrapedValue
+			outputSet[name] = value
+		}
+	}
+
+	m.Label = labelSetToLabelPairs(outputSet)
+}
+
+// labelSetToLabelPairs converts a LabelSet to a slice of LabelPair.
+// The slice will be sorted lexicographically by label name.
+func labelSetToLabelPairs(labels model.LabelSet) []*dto.LabelPair {
+	pairs := make([]*dto.LabelPair, 0, len(labels))
+
+	for name, value := range labels {
+		nameStr := string(name)
+		valueStr := string(value)
+
+		pairs = append(pairs, &dto.LabelPair{
+			Name:  &nameStr,
+			Value: &valueStr,
+		})
+	}
+
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i].GetName() < pairs[j].GetName()
+	})
+
+	return pairs
+}
@@ -0,0 +1,126 @@
+package promaggr
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+var (
+	// ErrSampleLimitExceeded is returned when the number of scraped samples exceeds the SampleLimit.
+	ErrSampleLimitExceeded = errors.New("sample limit exceeded")
+
+	// ErrLabelLimitExceeded is returned when the number of labels of a scraped metric exceeds the LabelLimit.
+	ErrLabelLimitExceeded = errors.New("label limit exceeded")
+
+	// ErrLabelNameLengthLimitExceeded is returned when the length of a scraped label name exceeds the LabelNameLengthLimit.
+	ErrLabelNameLengthLimitExceeded = errors.New("label name length limit exceeded")
+
+	// ErrLabelValueLengthLimitExceeded is returned when the length of a scraped label value exceeds the LabelValueLengthLimit.
+	ErrLabelValueLengthLimitExceeded = errors.New("label value length limit exceeded")
+)
+
+// LimitError is returned when the scraped metrics exceed one of the limits set on the Scraper.
+// Use errors.Is with ErrSampleLimitExceeded, ErrLabelLimitExceeded, ErrLabelNameLengthLimitExceeded
+// or ErrLabelValueLengthLimitExceeded to find out which limit has been exceeded.
+type LimitError struct {
+	// Err is the sentinel error of the exceeded limit.
+	Err error
+
+	// Limit is the value of the exceeded limit.
+	Limit int
+
+	// Metric is the name of the metric that exceeded the limit.
+	Metric string
+}
+
+// Error implements the error interface.
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%v: metric %s exceeded the limit of %d", e.Err, e.Metric, e.Limit)
+}
+
+// Unwrap returns the sentinel error of the exceeded limit.
+func (e *LimitError) Unwrap() error {
+	return e.Err
+}
+
+// limitName returns the name of the exceeded limit as used in the Prometheus scrape_config.
+func (e *LimitError) limitName() string {
+	switch {
+	case errors.Is(e.Err, ErrSampleLimitExceeded):
+		return "sample_limit"
+	case errors.Is(e.Err, ErrLabelLimitExceeded):
+		return "label_limit"
+	case errors.Is(e.Err, ErrLabelNameLengthLimitExceeded):
+		return "label_name_length_limit"
+	case errors.Is(e.Err, ErrLabelValueLengthLimitExceeded):
+		return "label_value_length_limit"
+	default:
+		return "unknown"
+	}
+}
+
+// limiter applies a set of limits to the scraped metrics.
+// A limit of 0 means no limit.
+type limiter struct {
+	sampleLimit           int
+	labelLimit            int
+	labelNameLengthLimit  int
+	labelValueLengthLimit int
+
+	// samples is the number of samples checked so far.
+	samples int
+}
+
+// check returns a LimitError if the given MetricFamily exceeds any of the limits.
+// The number of samples is accumulated across calls,
+// so that the limits can be checked while the MetricFamily's are decoded one by one.
+func (l *limiter) check(mf *dto.MetricFamily) error {
+	for _, m := range mf.GetMetric() {
+		l.samples += countSamples(mf.GetType(), m)
+		if l.sampleLimit > 0 && l.samples > l.sampleLimit {
+			return &LimitError{Err: ErrSampleLimitExceeded, Limit: l.sampleLimit, Metric: mf.GetName()}
+		}
+
+		if l.labelLimit > 0 && len(m.GetLabel()) > l.labelLimit {
+			return &LimitError{Err: ErrLabelLimitExceeded, Limit: l.labelLimit, Metric: mf.GetName()}
+		}
+
+		for _, lp := range m.GetLabel() {
+			if l.labelNameLengthLimit > 0 && len(lp.GetName()) > l.labelNameLengthLimit {
+				return &LimitError{Err: ErrLabelNameLengthLimitExceeded, Limit: l.labelNameLengthLimit, Metric: mf.GetName()}
+			}
+
+			if l.labelValueLengthLimit > 0 && len(lp.GetValue()) > l.labelValueLengthLimit {
+				return &LimitError{Err: ErrLabelValueLengthLimitExceeded, Limit: l.labelValueLengthLimit, Metric: mf.GetName()}
+			}
+		}
+	}
+
+	return nil
+}
+
+// countSamples returns the number of samples that the metric is exposed as.
+// Summaries and histograms are exposed as multiple samples, including the _sum and _count series.
+func countSamples(metricType dto.MetricType, m *dto.Metric) int {
+	switch metricType {
+	case dto.MetricType_SUMMARY:
+		return len(m.GetSummary().GetQuantile()) + 2
+	case dto.MetricType_HISTOGRAM:
+		buckets := m.GetHistogram().GetBucket()
+		samples := len(buckets) + 2
+
+		// The +Inf bucket is implicit in the protobuf format.
+		if len(buckets) == 0 || !math.IsInf(buckets[len(buckets)-1].GetUpperBound(), +1) {
+			samples++
+		}
+
+		return samples
+	case dto.MetricType_COUNTER, dto.MetricType_GAUGE, dto.MetricType_UNTYPED:
+		return 1
+	default:
+		return 1
+	}
+}
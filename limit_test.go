@@ -0,0 +1,113 @@
+package promaggr_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/d-kuro/promaggr"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+)
+
+func TestScraperLimits(t *testing.T) {
+	t.Parallel()
+
+	scrapeTarget := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", string(expfmt.FmtText))
+		_, _ = io.WriteString(w, metricsText)
+	}))
+	t.Cleanup(scrapeTarget.Close)
+
+	tests := []struct {
+		name    string
+		opts    []promaggr.ScraperOption
+		wantErr error
+		limit   string
+	}{
+		{
+			name:    "sample limit",
+			opts:    []promaggr.ScraperOption{promaggr.SampleLimit(10)},
+			wantErr: promaggr.ErrSampleLimitExceeded,
+			limit:   "sample_limit",
+		},
+		{
+			name: "label limit",
+			opts: []promaggr.ScraperOption{
+				promaggr.LabelLimit(1),
+				promaggr.Labels(model.LabelSet{"cluster": "foo"}),
+			},
+			wantErr: promaggr.ErrLabelLimitExceeded,
+			limit:   "label_limit",
+		},
+		{
+			name:    "label name length limit",
+			opts:    []promaggr.ScraperOption{promaggr.LabelNameLengthLimit(3)},
+			wantErr: promaggr.ErrLabelNameLengthLimitExceeded,
+			limit:   "label_name_length_limit",
+		},
+		{
+			name:    "label value length limit",
+			opts:    []promaggr.ScraperOption{promaggr.LabelValueLengthLimit(2)},
+			wantErr: promaggr.ErrLabelValueLengthLimitExceeded,
+			limit:   "label_value_length_limit",
+		},
+		{
+			name: "within limits",
+			opts: []promaggr.ScraperOption{promaggr.SampleLimit(20)},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			metrics := promaggr.NewScrapeMetrics()
+			scraper := promaggr.NewScraper(scrapeTarget.URL, append(tt.opts, promaggr.Metrics(metrics))...)
+
+			_, err := scraper.Scrape(context.Background())
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("failed to scrape: %v", err)
+				}
+
+				if got := testutil.CollectAndCount(metrics, "promaggr_scrapes_exceeded_limit_total"); got != 0 {
+					t.Errorf("unexpected number of exceeded limit metrics: want(0) got(%d)", got)
+				}
+
+				return
+			}
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("unexpected error: want(%v) got(%v)", tt.wantErr, err)
+			}
+
+			var limitErr *promaggr.LimitError
+			if !errors.As(err, &limitErr) {
+				t.Fatalf("error is not a LimitError: %v", err)
+			}
+
+			want := `# HELP promaggr_scrapes_exceeded_limit_total Total number of scrapes that failed because the scraped metrics exceeded a limit.
+# TYPE promaggr_scrapes_exceeded_limit_total counter
+promaggr_scrapes_exceeded_limit_total{limit="` + tt.limit + `",target="` + scrapeTarget.URL + `"} 1
+`
+
+			if err := testutil.CollectAndCompare(metrics, strings.NewReader(want), "promaggr_scrapes_exceeded_limit_total"); err != nil {
+				t.Errorf("exceeded limit metrics mismatch: %v", err)
+			}
+
+			// The metrics of a removed target are deleted.
+			metrics.DeleteTarget(scrapeTarget.URL)
+
+			if got := testutil.CollectAndCount(metrics); got != 0 {
+				t.Errorf("number of metrics mismatch after deleting the target: want(0) got(%d)", got)
+			}
+		})
+	}
+}
@@ -1,14 +1,23 @@
 package promaggr
 
 import (
+	"errors"
+	"fmt"
+	"sort"
+
 	dto "github.com/prometheus/client_model/go"
 	"github.com/prometheus/common/model"
 )
 
+// ErrTypeConflict is reported by MergeAll for a MetricFamily whose type differs from the one of another input.
+var ErrTypeConflict = errors.New("metric type conflict")
+
 // MergeOption is a functional option used by the MergeMetricFamily.
 type MergeOption func(mfs1, mfs2 []*dto.MetricFamily)
 
 // MergeMetricFamily returns the result of merging the slices of two MetricFamily's.
+// The result is sorted in the same order as prometheus.Registry:
+// the MetricFamily's are sorted by name, and the metrics are sorted by labels.
 // Be careful not to have metric with the same name and the same label when merging.
 // To avoid metric conflicts, you can use the built-in AddIdentifierLabel option.
 func MergeMetricFamily(mfs1, mfs2 []*dto.MetricFamily, opts ...MergeOption) []*dto.MetricFamily {
@@ -30,17 +39,169 @@ func MergeMetricFamily(mfs1, mfs2 []*dto.MetricFamily, opts ...MergeOption) []*d
 
 	mergedMfs := make([]*dto.MetricFamily, 0, len(mfSet))
 	for _, mf := range mfSet {
+		for _, m := range mf.GetMetric() {
+			sortLabelPairs(m.Label)
+		}
+
+		sortMetrics(mf.Metric)
 		mergedMfs = append(mergedMfs, mf)
 	}
 
+	sortMetricFamilies(mergedMfs)
+
 	return mergedMfs
 }
 
 // AddIdentifierLabel is an option available for MergeMetricFamily.
 // Add labels for identifiers to avoid metric conflicts when merging.
+//
+// Deprecated: The mfs1 and mfs2 arguments are ignored, and the labels are added to the MetricFamily's
+// passed to MergeMetricFamily instead. Use MergeAll with the IdentifierLabel option.
 func AddIdentifierLabel(mfs1, mfs2 []*dto.MetricFamily, label model.LabelName, mfs1Identifier, mfs2Identifier model.LabelValue) MergeOption {
 	return func(mfs1, mfs2 []*dto.MetricFamily) {
 		AddLabels(mfs1, model.LabelSet{label: mfs1Identifier})
 		AddLabels(mfs2, model.LabelSet{label: mfs2Identifier})
 	}
 }
+
+// MergeAllOption is a functional option used by the MergeAll.
+type MergeAllOption func(*mergeAllConfig)
+
+// mergeAllConfig is the configuration of MergeAll.
+type mergeAllConfig struct {
+	identifierLabel model.LabelName
+}
+
+// IdentifierLabel is an option available for MergeAll.
+// The identifier of each input is added to its metrics as a label of the given name,
+// to avoid metric conflicts when merging.
+func IdentifierLabel(name model.LabelName) MergeAllOption {
+	return func(c *mergeAllConfig) {
+		c.identifierLabel = name
+	}
+}
+
+// Conflict is a MetricFamily or a metric dropped by MergeAll because it conflicts with another input.
+type Conflict struct {
+	// Name is the name of the MetricFamily.
+	Name string
+
+	// Labels is the labels of the dropped metric.
+	// It is nil if the whole MetricFamily is dropped because of a type conflict.
+	Labels model.LabelSet
+
+	// Identifier is the identifier of the input that the dropped MetricFamily or metric belongs to.
+	Identifier string
+
+	// ConflictsWith is the identifier of the input that the kept MetricFamily or metric belongs to.
+	ConflictsWith string
+
+	// Err is ErrTypeConflict or ErrDuplicateMetric.
+	Err error
+}
+
+// Error implements the error interface.
+func (c Conflict) Error() string {
+	if c.Labels == nil {
+		return fmt.Sprintf("%v: %s of %s conflicts with %s", c.Err, c.Name, c.Identifier, c.ConflictsWith)
+	}
+
+	return fmt.Sprintf("%v: %s%s of %s conflicts with %s", c.Err, c.Name, c.Labels, c.Identifier, c.ConflictsWith)
+}
+
+// Unwrap returns ErrTypeConflict or ErrDuplicateMetric.
+func (c Conflict) Unwrap() error {
+	return c.Err
+}
+
+// MergeAll returns the result of merging any number of inputs of MetricFamily's.
+// The key of the inputs is the identifier of each input, which can be added as a label with the IdentifierLabel option.
+//
+// The inputs are merged in the order of the identifiers. If a MetricFamily has a different type from the one
+// of a preceding input, or a metric has the same name and labels as one of a preceding input,
+// it is dropped and reported as a Conflict.
+// The result is sorted in the same order as prometheus.Registry, and the inputs are not modified.
+func MergeAll(inputs map[string][]*dto.MetricFamily, opts ...MergeAllOption) ([]*dto.MetricFamily, []Conflict) {
+	var config mergeAllConfig
+
+	for _, o := range opts {
+		o(&config)
+	}
+
+	identifiers := make([]string, 0, len(inputs))
+	for identifier := range inputs {
+		identifiers = append(identifiers, identifier)
+	}
+
+	sort.Strings(identifiers)
+
+	var conflicts []Conflict
+
+	mfSet := make(map[string]*dto.MetricFamily)
+	mfOwners := make(map[string]string)
+	metricOwners := make(map[string]string)
+
+	for _, identifier := range identifiers {
+		for _, mf := range inputs[identifier] {
+			merged, ok := mfSet[mf.GetName()]
+			if !ok {
+				merged = &dto.MetricFamily{
+					Name: mf.Name,
+					Help: mf.Help,
+					Type: mf.Type,
+				}
+				mfSet[mf.GetName()] = merged
+				mfOwners[mf.GetName()] = identifier
+			}
+
+			if merged.GetType() != mf.GetType() {
+				conflicts = append(conflicts, Conflict{
+					Name:          mf.GetName(),
+					Identifier:    identifier,
+					ConflictsWith: mfOwners[mf.GetName()],
+					Err:           ErrTypeConflict,
+				})
+
+				continue
+			}
+
+			for _, m := range mf.GetMetric() {
+				m = copyMetric(m)
+
+				if config.identifierLabel != "" {
+					addTargetLabelsToMetric(m, model.LabelSet{config.identifierLabel: model.LabelValue(identifier)}, false, true)
+				}
+
+				id := mf.GetName() + labelPairsString(m.GetLabel())
+				if owner, ok := metricOwners[id]; ok {
+					conflicts = append(conflicts, Conflict{
+						Name:          mf.GetName(),
+						Labels:        model.LabelSet(newLabelSet(m.GetLabel())),
+						Identifier:    identifier,
+						ConflictsWith: owner,
+						Err:           ErrDuplicateMetric,
+					})
+
+					continue
+				}
+
+				metricOwners[id] = identifier
+				merged.Metric = append(merged.Metric, m)
+			}
+		}
+	}
+
+	mergedMfs := make([]*dto.MetricFamily, 0, len(mfSet))
+	for _, mf := range mfSet {
+		if len(mf.Metric) == 0 {
+			continue
+		}
+
+		sortMetrics(mf.Metric)
+		mergedMfs = append(mergedMfs, mf)
+	}
+
+	sortMetricFamilies(mergedMfs)
+
+	return mergedMfs, conflicts
+}
@@ -2,7 +2,6 @@ package promaggr_test
 
 import (
 	"bytes"
-	"sort"
 	"testing"
 
 	"github.com/d-kuro/promaggr"
@@ -10,6 +9,7 @@ import (
 	"github.com/google/go-cmp/cmp"
 	dto "github.com/prometheus/client_model/go"
 	"github.com/prometheus/common/expfmt"
+	"google.golang.org/protobuf/testing/protocmp"
 )
 
 func TestMergeMetricFamily(t *testing.T) {
@@ -38,10 +38,6 @@ func TestMergeMetricFamily(t *testing.T) {
 
 	out := bytes.Buffer{}
 
-	sort.Slice(mfs, func(i, j int) bool {
-		return mfs[i].GetName() < mfs[j].GetName()
-	})
-
 	for _, mf := range mfs {
 		if _, err := expfmt.MetricFamilyToText(&out, mf); err != nil {
 			t.Fatalf("failed to convert MetricFamily to text: %v", err)
@@ -51,15 +47,97 @@ func TestMergeMetricFamily(t *testing.T) {
 	got := out.String()
 	want := `# HELP dummy_counter_metric Dummy text.
 # TYPE dummy_counter_metric counter
-dummy_counter_metric{cluster_name="foo"} 123456
 dummy_counter_metric{cluster_name="bar"} 123456
+dummy_counter_metric{cluster_name="foo"} 123456
 # HELP dummy_gauge_metric Dummy text.
 # TYPE dummy_gauge_metric gauge
-dummy_gauge_metric{cluster_name="foo"} 123.456
 dummy_gauge_metric{cluster_name="bar"} 123.456
+dummy_gauge_metric{cluster_name="foo"} 123.456
 `
 
 	if diff := cmp.Diff(want, got); diff != "" {
 		t.Errorf("MergeMetricFamily() mismatch (-want +got):\n%s", diff)
 	}
 }
+
+func TestMergeAll(t *testing.T) {
+	t.Parallel()
+
+	const metricName = "dummy_metric"
+
+	newInputs := func() map[string][]*dto.MetricFamily {
+		return map[string][]*dto.MetricFamily{
+			"foo": {internal.NewCounterMetricFamilyFixture(metricName)},
+			"bar": {internal.NewCounterMetricFamilyFixture(metricName)},
+			"baz": {internal.NewGaugeMetricFamilyFixture(metricName)},
+		}
+	}
+
+	tests := []struct {
+		name          string
+		opts          []promaggr.MergeAllOption
+		want          string
+		wantConflicts []string
+	}{
+		{
+			name: "with identifier label",
+			opts: []promaggr.MergeAllOption{promaggr.IdentifierLabel("cluster_name")},
+			want: `# HELP dummy_metric Dummy text.
+# TYPE dummy_metric counter
+dummy_metric{cluster_name="bar"} 123456
+dummy_metric{cluster_name="foo"} 123456
+`,
+			wantConflicts: []string{
+				"metric type conflict: dummy_metric of baz conflicts with bar",
+			},
+		},
+		{
+			name: "without identifier label",
+			want: `# HELP dummy_metric Dummy text.
+# TYPE dummy_metric counter
+dummy_metric 123456
+`,
+			wantConflicts: []string{
+				"metric type conflict: dummy_metric of baz conflicts with bar",
+				"duplicate metric: dummy_metric{} of foo conflicts with bar",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			inputs := newInputs()
+
+			mfs, conflicts := promaggr.MergeAll(inputs, tt.opts...)
+
+			out := bytes.Buffer{}
+
+			for _, mf := range mfs {
+				if _, err := expfmt.MetricFamilyToText(&out, mf); err != nil {
+					t.Fatalf("failed to convert MetricFamily to text: %v", err)
+				}
+			}
+
+			if diff := cmp.Diff(tt.want, out.String()); diff != "" {
+				t.Errorf("MergeAll() mismatch (-want +got):\n%s", diff)
+			}
+
+			gotConflicts := make([]string, 0, len(conflicts))
+			for _, conflict := range conflicts {
+				gotConflicts = append(gotConflicts, conflict.Error())
+			}
+
+			if diff := cmp.Diff(tt.wantConflicts, gotConflicts); diff != "" {
+				t.Errorf("conflicts mismatch (-want +got):\n%s", diff)
+			}
+
+			// The inputs must not be modified.
+			if diff := cmp.Diff(newInputs(), inputs, protocmp.Transform()); diff != "" {
+				t.Errorf("inputs are modified (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package promaggr
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var _ prometheus.Collector = &ScrapeMetrics{}
+
+// limitNames is the values of the "limit" label of promaggr_scrapes_exceeded_limit_total.
+var limitNames = []string{
+	"sample_limit",
+	"label_limit",
+	"label_name_length_limit",
+	"label_value_length_limit",
+	"body_size_limit",
+	"unknown",
+}
+
+// ScrapeMetrics is a set of metrics about the scrapes of Scrapers.
+// It implements the prometheus.Collector interface, so it can be registered to a prometheus.Registry.
+// The metrics are partitioned by the "target" label, which is the URL of the Scraper.
+type ScrapeMetrics struct {
+	exceededLimit     *prometheus.CounterVec
+	compressedBytes   *prometheus.CounterVec
+	uncompressedBytes *prometheus.CounterVec
+}
+
+// NewScrapeMetrics creates and returns a new ScrapeMetrics.
+func NewScrapeMetrics() *ScrapeMetrics {
+	return &ScrapeMetrics{
+		exceededLimit: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "promaggr_scrapes_exceeded_limit_total",
+				Help: "Total number of scrapes that failed because the scraped metrics exceeded a limit.",
+			},
+			[]string{"target", "limit"},
+		),
+		compressedBytes: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "promaggr_scrape_compressed_bytes_total",
+				Help: "Total number of bytes of the response bodies received from the target, before decompression.",
+			},
+			[]string{"target"},
+		),
+		uncompressedBytes: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "promaggr_scrape_uncompressed_bytes_total",
+				Help: "Total number of bytes of the response bodies received from the target, after decompression.",
+			},
+			[]string{"target"},
+		),
+	}
+}
+
+// Describe implements the prometheus.Collector interface.
+func (m *ScrapeMetrics) Describe(ch chan<- *prometheus.Desc) {
+	m.exceededLimit.Describe(ch)
+	m.compressedBytes.Describe(ch)
+	m.uncompressedBytes.Describe(ch)
+}
+
+// Collect implements the prometheus.Collector interface.
+func (m *ScrapeMetrics) Collect(ch chan<- prometheus.Metric) {
+	m.exceededLimit.Collect(ch)
+	m.compressedBytes.Collect(ch)
+	m.uncompressedBytes.Collect(ch)
+}
+
+// incExceededLimit increments the number of scrapes that exceeded the limit.
+// It is safe to call on a nil ScrapeMetrics.
+func (m *ScrapeMetrics) incExceededLimit(target, limit string) {
+	if m == nil {
+		return
+	}
+
+	m.exceededLimit.WithLabelValues(target, limit).Inc()
+}
+
+// addBytes adds the number of bytes of a response body before and after decompression.
+// It is safe to call on a nil ScrapeMetrics.
+func (m *ScrapeMetrics) addBytes(target string, compressed, uncompressed int64) {
+	if m == nil {
+		return
+	}
+
+	m.compressedBytes.WithLabelValues(target).Add(float64(compressed))
+	m.uncompressedBytes.WithLabelValues(target).Add(float64(uncompressed))
+}
+
+// DeleteTarget deletes the metrics of the target, which is the URL of a Scraper.
+// Call it when a Scraper is removed so that the metrics of the removed target are no longer exposed.
+func (m *ScrapeMetrics) DeleteTarget(target string) {
+	for _, limit := range limitNames {
+		m.exceededLimit.DeleteLabelValues(target, limit)
+	}
+
+	m.compressedBytes.DeleteLabelValues(target)
+	m.uncompressedBytes.DeleteLabelValues(target)
+}
@@ -0,0 +1,170 @@
+package promaggr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// scrapeTimeoutHeader is the header in which Prometheus sends the scrape timeout in seconds.
+const scrapeTimeoutHeader = "X-Prometheus-Scrape-Timeout-Seconds"
+
+var (
+	// ErrMissingTarget is returned when the target parameter is not given to the ProbeHandler.
+	ErrMissingTarget = errors.New("target parameter is missing")
+
+	// ErrUnknownModule is returned when the module given to the ProbeHandler is not configured.
+	ErrUnknownModule = errors.New("unknown module")
+)
+
+var _ http.Handler = &ProbeHandler{}
+
+// ProbeModule builds the Scrapers of the target given to the ProbeHandler.
+// The target is the value of the target parameter, e.g. "localhost:9100" or "http://localhost:9100/metrics".
+type ProbeModule func(target string) ([]*Scraper, error)
+
+// ProbeHandlerOption is a functional option used by the NewProbeHandler.
+type ProbeHandlerOption func(*ProbeHandler)
+
+// ProbeHandler is an http.Handler which scrapes the target given by the request, like the blackbox_exporter.
+// The target and the module are given as the parameters, e.g. "/probe?target=localhost:9100&module=node".
+// The Scrapers built by the module are scraped, and the merged metrics are returned.
+// If any of the Scrapers fails, the request fails with 500 so that Prometheus reports the target as down.
+//
+// Prometheus can select the targets by relabeling the __address__ into the __param_target,
+// so that a ProbeHandler serves any number of targets without listing them.
+type ProbeHandler struct {
+	// Modules is a set of ProbeModule's by name.
+	Modules map[string]ProbeModule
+
+	// DefaultModule is the name of the module used when the module parameter is not given.
+	// If not specified, the module parameter is required.
+	DefaultModule string
+
+	// Logger is a logger that implements the logr.Logger interface.
+	// If it is not specified, nothing will be logged.
+	Logger logr.Logger
+}
+
+// NewProbeHandler creates and returns a new ProbeHandler which scrapes the targets by the modules.
+func NewProbeHandler(modules map[string]ProbeModule, opts ...ProbeHandlerOption) *ProbeHandler {
+	handler := &ProbeHandler{
+		Modules: modules,
+	}
+
+	for _, o := range opts {
+		o(handler)
+	}
+
+	return handler
+}
+
+// ProbeHandlerDefaultModule is an option available for NewProbeHandler.
+// The module of the given name is used when the module parameter is not given.
+func ProbeHandlerDefaultModule(name string) ProbeHandlerOption {
+	return func(h *ProbeHandler) {
+		h.DefaultModule = name
+	}
+}
+
+// ProbeHandlerLogger is an option available for NewProbeHandler.
+// If a logger is set, the failed probes will be output to the log.
+func ProbeHandlerLogger(logger logr.Logger) ProbeHandlerOption {
+	return func(h *ProbeHandler) {
+		h.Logger = logger
+	}
+}
+
+// ServeHTTP implements the http.Handler interface.
+// If the request has the X-Prometheus-Scrape-Timeout-Seconds header, the probe is canceled after the timeout.
+func (h *ProbeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	target := query.Get("target")
+	if target == "" {
+		http.Error(w, ErrMissingTarget.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	name := query.Get("module")
+	if name == "" {
+		name = h.DefaultModule
+	}
+
+	module, ok := h.Modules[name]
+	if !ok {
+		http.Error(w, fmt.Sprintf("%s: %q", ErrUnknownModule, name), http.StatusBadRequest)
+
+		return
+	}
+
+	scrapers, err := module(target)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to build scrapers of %s: %s", target, err), http.StatusBadRequest)
+
+		return
+	}
+
+	ctx := r.Context()
+
+	if seconds, err := strconv.ParseFloat(r.Header.Get(scrapeTimeoutHeader), 64); err == nil && seconds > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(seconds*float64(time.Second)))
+		defer cancel()
+	}
+
+	mfs, err := h.probe(ctx, scrapers)
+	if err != nil {
+		if h.Logger != nil {
+			h.Logger.Error(err, "failed to probe target", "target", target, "module", name)
+		}
+
+		http.Error(w, fmt.Sprintf("failed to probe %s: %s", target, err), http.StatusInternalServerError)
+
+		return
+	}
+
+	promhttp.HandlerFor(
+		prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) { return normalizeMetricFamilies(mfs) }),
+		promhttp.HandlerOpts{ErrorHandling: promhttp.ContinueOnError},
+	).ServeHTTP(w, r)
+}
+
+// probe scrapes the Scrapers concurrently and merges the results.
+// It fails if any of the Scrapers fails.
+func (h *ProbeHandler) probe(ctx context.Context, scrapers []*Scraper) ([]*dto.MetricFamily, error) {
+	sources := make([]Source, 0, len(scrapers))
+	for _, scraper := range scrapers {
+		sources = append(sources, scraper)
+	}
+
+	inputs := make(map[string][]*dto.MetricFamily, len(sources))
+
+	for _, result := range fetchAll(ctx, sources) {
+		if result.err != nil {
+			return nil, result.err
+		}
+
+		inputs[result.identifier] = result.mfs
+	}
+
+	mfs, conflicts := MergeAll(inputs)
+
+	if h.Logger != nil {
+		for _, conflict := range conflicts {
+			h.Logger.Error(conflict, "dropped conflicting metrics while merging")
+		}
+	}
+
+	return mfs, nil
+}
@@ -0,0 +1,91 @@
+package promaggr_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/d-kuro/promaggr"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/common/model"
+)
+
+func TestProbeHandler(t *testing.T) {
+	t.Parallel()
+
+	counter := newHTTPRequestCounter()
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(counter)
+	counter.WithLabelValues("200", http.MethodGet).Inc()
+
+	target := httptest.NewServer(promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	t.Cleanup(target.Close)
+
+	unavailable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(unavailable.Close)
+
+	handler := promaggr.NewProbeHandler(
+		map[string]promaggr.ProbeModule{
+			"default": func(target string) ([]*promaggr.Scraper, error) {
+				return []*promaggr.Scraper{
+					promaggr.NewScraper(target, promaggr.Labels(model.LabelSet{"instance": model.LabelValue(target)})),
+				}, nil
+			},
+		},
+		promaggr.ProbeHandlerDefaultModule("default"),
+	)
+
+	tests := []struct {
+		name     string
+		query    string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "success",
+			query:    "?target=" + target.URL,
+			wantCode: http.StatusOK,
+			wantBody: `http_requests_total{code="200",instance="` + target.URL + `",method="GET"} 1`,
+		},
+		{
+			name:     "missing target",
+			query:    "",
+			wantCode: http.StatusBadRequest,
+			wantBody: "target parameter is missing",
+		},
+		{
+			name:     "unknown module",
+			query:    "?target=" + target.URL + "&module=unknown",
+			wantCode: http.StatusBadRequest,
+			wantBody: `unknown module: "unknown"`,
+		},
+		{
+			name:     "failed to scrape",
+			query:    "?target=" + unavailable.URL,
+			wantCode: http.StatusInternalServerError,
+			wantBody: "unexpected status code: 503",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/probe"+tt.query, nil))
+
+			if rec.Code != tt.wantCode {
+				t.Errorf("status code mismatch: want(%d) got(%d)", tt.wantCode, rec.Code)
+			}
+
+			if body := rec.Body.String(); !strings.Contains(body, tt.wantBody) {
+				t.Errorf("response does not contain %q:\n%s", tt.wantBody, body)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package promaggr
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+var (
+	_ Source = &Scraper{}
+	_ Source = &FileSource{}
+	_ Source = SourceFunc(nil)
+)
+
+// Source is a source of the metrics aggregated by the Collector.
+// Any type that implements the Source interface can be aggregated in the same way as the Scraper.
+type Source interface {
+	// Fetch returns the metrics of the source.
+	// It is called concurrently with the other sources of the Collector.
+	Fetch(ctx context.Context) ([]*dto.MetricFamily, error)
+}
+
+// SourceFunc is an adapter to allow the use of ordinary functions as Source.
+// Its identifier is the type name, so give a type implementing fmt.Stringer instead
+// if the source should be identified regardless of the order of the sources.
+type SourceFunc func(ctx context.Context) ([]*dto.MetricFamily, error)
+
+// Fetch implements the Source interface.
+func (f SourceFunc) Fetch(ctx context.Context) ([]*dto.MetricFamily, error) {
+	return f(ctx)
+}
+
+// sourceIdentifiers returns the identifiers of the sources, which are used to merge and to report their results.
+// The identifiers are unique, and a suffix is added to the identifier of a source that is the same as another one.
+func sourceIdentifiers(sources []Source) []string {
+	identifiers := make([]string, 0, len(sources))
+	used := make(map[string]struct{}, len(sources))
+	count := make(map[string]int, len(sources))
+
+	for _, source := range sources {
+		used[sourceIdentifier(source)] = struct{}{}
+	}
+
+	for _, source := range sources {
+		base := sourceIdentifier(source)
+
+		count[base]++
+		identifier := base
+
+		// The suffix is skipped while it collides with the identifier of another source.
+		for n := count[base]; n > 1; n++ {
+			identifier = fmt.Sprintf("%s#%d", base, n)
+			if _, ok := used[identifier]; !ok {
+				count[base] = n
+				used[identifier] = struct{}{}
+
+				break
+			}
+		}
+
+		identifiers = append(identifiers, identifier)
+	}
+
+	return identifiers
+}
+
+// sourceIdentifier returns the identifier of the source.
+// If the source has no name, the type name is used.
+func sourceIdentifier(source Source) string {
+	switch s := source.(type) {
+	case *Scraper:
+		return s.ID()
+	case *FileSource:
+		return s.Dir
+	case *ExecSource:
+		return s.Command
+	case *GathererSource:
+		if s.Name != "" {
+			return s.Name
+		}
+
+		return fmt.Sprintf("%T", source)
+	case fmt.Stringer:
+		return s.String()
+	default:
+		return fmt.Sprintf("%T", source)
+	}
+}
+
+// fetchResult is the result of a fetch of a source.
+type fetchResult struct {
+	identifier string
+	mfs        []*dto.MetricFamily
+	err        error
+	start      time.Time
+	duration   time.Duration
+}
+
+// fetchAll fetches the metrics from the sources concurrently.
+// The results are in the same order as the sources, and are identified by the sourceIdentifiers.
+func fetchAll(ctx context.Context, sources []Source) []fetchResult {
+	identifiers := sourceIdentifiers(sources)
+	results := make([]fetchResult, len(sources))
+
+	var wg sync.WaitGroup
+
+	for i, source := range sources {
+		i, source := i, source
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			start := time.Now()
+			mfs, err := source.Fetch(ctx)
+
+			results[i] = fetchResult{
+				identifier: identifiers[i],
+				mfs:        mfs,
+				err:        err,
+				start:      start,
+				duration:   time.Since(start),
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	return results
+}
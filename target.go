@@ -0,0 +1,102 @@
+package promaggr
+
+import (
+	"time"
+
+	"github.com/prometheus/common/model"
+)
+
+// TargetHealth is the health of a source of the Collector.
+type TargetHealth string
+
+const (
+	// HealthUnknown is the health of a source which has not been fetched yet.
+	HealthUnknown TargetHealth = "unknown"
+
+	// HealthUp is the health of a source whose last fetch succeeded.
+	HealthUp TargetHealth = "up"
+
+	// HealthDown is the health of a source whose last fetch failed.
+	HealthDown TargetHealth = "down"
+)
+
+// TargetStatus is the state of the last fetch of a source of the Collector.
+type TargetStatus struct {
+	// Identifier is the identifier of the source, which is used to merge and to report its results.
+	Identifier string
+
+	// URL is the URL of the Scraper. It is empty for the other sources.
+	URL string
+
+	// Labels is the labels of the Scraper. It is nil for the other sources.
+	Labels model.LabelSet
+
+	// Health is the health of the source.
+	Health TargetHealth
+
+	// LastScrape is the time when the last fetch started. It is zero if the source has not been fetched yet.
+	LastScrape time.Time
+
+	// LastScrapeDuration is the duration of the last fetch.
+	LastScrapeDuration time.Duration
+
+	// Samples is the number of samples fetched by the last fetch. It is 0 if the last fetch failed.
+	Samples int
+
+	// LastError is the error of the last fetch. It is nil if the last fetch succeeded.
+	LastError error
+}
+
+// Targets returns the status of each Scraper and the other sources of the Collector.
+// The sources which have not been fetched yet have the HealthUnknown.
+// The status is only refreshed when the Collector is gathered, as the Collector does not scrape by itself.
+// The scrapes by a ProbeHandler are not tracked, as it does not belong to any Collector.
+func (c *Collector) Targets() []TargetStatus {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	sources := c.sourcesLocked()
+	identifiers := sourceIdentifiers(sources)
+	statuses := make([]TargetStatus, 0, len(sources))
+
+	for i, source := range sources {
+		status, ok := c.statuses[identifiers[i]]
+		if !ok {
+			status = TargetStatus{Identifier: identifiers[i], Health: HealthUnknown}
+		}
+
+		if scraper, ok := source.(*Scraper); ok {
+			status.URL = scraper.URL
+			status.Labels = scraper.Labels.Clone()
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	return statuses
+}
+
+// newTargetStatus returns the status of the result of a fetch.
+func newTargetStatus(result fetchResult) TargetStatus {
+	status := TargetStatus{
+		Identifier:         result.identifier,
+		Health:             HealthUp,
+		LastScrape:         result.start,
+		LastScrapeDuration: result.duration,
+	}
+
+	if result.err != nil {
+		status.Health = HealthDown
+		status.LastError = result.err
+
+		return status
+	}
+
+	for _, mf := range result.mfs {
+		for _, m := range mf.GetMetric() {
+			status.Samples += countSamples(mf.GetType(), m)
+		}
+	}
+
+	return status
+}
@@ -0,0 +1,132 @@
+package promaggr_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/d-kuro/promaggr"
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/model"
+)
+
+func TestCollectorTargets(t *testing.T) {
+	t.Parallel()
+
+	counter := newHTTPRequestCounter()
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(counter)
+	counter.WithLabelValues("200", http.MethodGet).Inc()
+	counter.WithLabelValues("500", http.MethodGet).Inc()
+
+	up := httptest.NewServer(promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	defer up.Close()
+
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	collector := promaggr.NewCollector([]*promaggr.Scraper{
+		promaggr.NewScraper(up.URL, promaggr.Labels(model.LabelSet{"job": "up"})),
+		promaggr.NewScraper(down.URL),
+	})
+
+	for _, status := range collector.Targets() {
+		if status.Health != promaggr.HealthUnknown {
+			t.Errorf("health of %s mismatch before scrape: want(%s) got(%s)", status.Identifier, promaggr.HealthUnknown, status.Health)
+		}
+	}
+
+	if _, err := collector.Gather(); err != nil {
+		t.Fatalf("failed to gather: %v", err)
+	}
+
+	statuses := collector.Targets()
+	if len(statuses) != 2 {
+		t.Fatalf("number of targets mismatch: want(2) got(%d)", len(statuses))
+	}
+
+	if got := statuses[0]; got.Health != promaggr.HealthUp || got.Samples != 2 || got.LastError != nil ||
+		got.URL != up.URL || got.Labels["job"] != "up" || got.LastScrape.IsZero() {
+		t.Errorf("unexpected status of the healthy target: %+v", got)
+	}
+
+	if got := statuses[1]; got.Health != promaggr.HealthDown || got.Samples != 0 ||
+		!errors.Is(got.LastError, promaggr.ErrUnexpectedStatusCode) {
+		t.Errorf("unexpected status of the unhealthy target: %+v", got)
+	}
+
+	// The labels of the status are a copy of the ones of the Scraper.
+	statuses[0].Labels["job"] = "modified"
+
+	if got := collector.ScraperList()[0].Labels["job"]; got != "up" {
+		t.Errorf("labels of the scraper are modified through the status: job(%s)", got)
+	}
+
+	// The status of a removed Scraper is dropped.
+	collector.RemoveScraper(collector.ScraperList()[1].ID())
+
+	if got := len(collector.Targets()); got != 1 {
+		t.Errorf("number of targets mismatch: want(1) got(%d)", got)
+	}
+}
+
+type namedSource string
+
+func (s namedSource) Fetch(ctx context.Context) ([]*dto.MetricFamily, error) {
+	return nil, nil
+}
+
+func (s namedSource) String() string {
+	return string(s)
+}
+
+func TestCollectorTargetsIdentifiers(t *testing.T) {
+	t.Parallel()
+
+	collector := promaggr.NewCollector(nil, promaggr.Sources(
+		namedSource("source"),
+		namedSource("source"),
+		namedSource("source#2"),
+		namedSource("source"),
+	))
+
+	got := make([]string, 0, 4)
+	for _, status := range collector.Targets() {
+		got = append(got, status.Identifier)
+	}
+
+	// The suffixed identifiers do not collide with the identifier of another source.
+	want := []string{"source", "source#3", "source#2", "source#4"}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("identifiers mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestCollectorTargetsGathererSourceName(t *testing.T) {
+	t.Parallel()
+
+	collector := promaggr.NewCollector(nil, promaggr.Sources(
+		promaggr.NewGathererSource(prometheus.NewRegistry(), promaggr.GathererSourceName("sidecar")),
+		promaggr.NewGathererSource(prometheus.NewRegistry(), promaggr.GathererSourceName("app")),
+		promaggr.NewGathererSource(prometheus.NewRegistry()),
+	))
+
+	got := make([]string, 0, 3)
+	for _, status := range collector.Targets() {
+		got = append(got, status.Identifier)
+	}
+
+	want := []string{"sidecar", "app", "*promaggr.GathererSource"}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("identifiers mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,231 @@
+package promaggr
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-logr/logr"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+)
+
+const (
+	// textfileExtension is the extension of the files read by the FileSource.
+	textfileExtension = ".prom"
+
+	textfileMtimeMetricName       = "promaggr_textfile_mtime_seconds"
+	textfileScrapeErrorMetricName = "promaggr_textfile_scrape_error"
+)
+
+// FileSourceOption is a functional option used by the NewFileSource.
+type FileSourceOption func(*FileSource)
+
+// FileSource reads metrics from the *.prom files in a directory,
+// in the same way as the textfile collector of node_exporter.
+// In addition to the metrics in the files, the modification time of each file
+// and whether it failed to be read are exposed as the promaggr_textfile_mtime_seconds
+// and promaggr_textfile_scrape_error metrics with the "file" label.
+type FileSource struct {
+	// Dir is the directory to read the *.prom files from.
+	Dir string
+
+	// Labels is a set of labels to be added to the metrics read from all files.
+	// If not specified, nothing will be added.
+	Labels model.LabelSet
+
+	// FileLabels is a set of labels to be added to the metrics read from each file.
+	// The key is the name of the file, e.g. "batch.prom".
+	// The labels take precedence over the Labels.
+	FileLabels map[string]model.LabelSet
+
+	// Logger is a logger that implements the logr.Logger interface.
+	// If it is not specified, nothing will be logged.
+	Logger logr.Logger
+}
+
+// NewFileSource creates and returns a new FileSource.
+func NewFileSource(dir string, opts ...FileSourceOption) *FileSource {
+	source := &FileSource{
+		Dir: dir,
+	}
+
+	for _, o := range opts {
+		o(source)
+	}
+
+	return source
+}
+
+// FileSourceLabels is an option available for NewFileSource.
+// This will be used to add labels to the metrics read from all files.
+func FileSourceLabels(labelSets model.LabelSet) FileSourceOption {
+	return func(s *FileSource) {
+		s.Labels = labelSets
+	}
+}
+
+// FileLabels is an option available for NewFileSource.
+// This will be used to add labels to the metrics read from the file of the given name.
+func FileLabels(fileName string, labelSets model.LabelSet) FileSourceOption {
+	return func(s *FileSource) {
+		if s.FileLabels == nil {
+			s.FileLabels = make(map[string]model.LabelSet)
+		}
+
+		s.FileLabels[fileName] = labelSets
+	}
+}
+
+// FileSourceLogger is an option available for NewFileSource.
+// If a logger is set, the files that fail to be read and the conflicting metrics will be output to the log.
+func FileSourceLogger(logger logr.Logger) FileSourceOption {
+	return func(s *FileSource) {
+		s.Logger = logger
+	}
+}
+
+// Fetch reads the metrics from the *.prom files in the directory.
+// A file that fails to be read is skipped, and it is reported by the promaggr_textfile_scrape_error metric
+// and logged along with the error.
+// The metrics of the files are merged by MergeAll, so that the conflicting metrics are dropped.
+// An error is returned only if the directory cannot be read.
+func (s *FileSource) Fetch(ctx context.Context) ([]*dto.MetricFamily, error) {
+	if _, err := os.Stat(s.Dir); err != nil {
+		return nil, fmt.Errorf("failed to read directory %s: %w", s.Dir, err)
+	}
+
+	// The files are returned in lexical order.
+	files, err := filepath.Glob(filepath.Join(s.Dir, "*"+textfileExtension))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files in %s: %w", s.Dir, err)
+	}
+
+	mtimeMf := newTextfileGaugeMetricFamily(textfileMtimeMetricName, "Unixtime mtime of textfiles successfully read.")
+	errorMf := newTextfileGaugeMetricFamily(textfileScrapeErrorMetricName, "1 if there was an error reading the textfile, 0 otherwise.")
+
+	inputs := make(map[string][]*dto.MetricFamily, len(files))
+
+	for _, file := range files {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read files in %s: %w", s.Dir, err)
+		}
+
+		fileMfs, mtime, err := s.readFile(file)
+		if err != nil {
+			if s.Logger != nil {
+				s.Logger.Error(err, "failed to read textfile", "file", file)
+			}
+
+			addTextfileGauge(errorMf, file, 1)
+
+			continue
+		}
+
+		addTextfileGauge(errorMf, file, 0)
+		addTextfileGauge(mtimeMf, file, float64(mtime.UnixNano())/1e9)
+
+		inputs[file] = fileMfs
+	}
+
+	mfs, conflicts := MergeAll(inputs)
+
+	if s.Logger != nil {
+		for _, conflict := range conflicts {
+			s.Logger.Error(conflict, "dropped conflicting metrics while merging textfiles")
+		}
+	}
+
+	for _, mf := range []*dto.MetricFamily{mtimeMf, errorMf} {
+		if len(mf.Metric) > 0 {
+			mfs = append(mfs, mf)
+		}
+	}
+
+	sortMetricFamilies(mfs)
+
+	return mfs, nil
+}
+
+// readFile reads the metrics from the file and returns them with the modification time of the file.
+func (s *FileSource) readFile(file string) ([]*dto.MetricFamily, time.Time, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to open %s: %w", file, err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to stat %s: %w", file, err)
+	}
+
+	labels := s.Labels.Merge(s.FileLabels[filepath.Base(file)])
+	r := bufio.NewReader(f)
+
+	mfs, err := decodeMetricFamilies(NewDecoder(r, detectFormat(r)), func(mf *dto.MetricFamily) error {
+		dropTimestamps(mf)
+
+		if len(labels) > 0 {
+			addTargetLabels([]*dto.MetricFamily{mf}, labels, false, true)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to parse %s: %w", file, err)
+	}
+
+	return mfs, info.ModTime(), nil
+}
+
+// detectFormat detects the format of the metrics from the first bytes of the reader without consuming them.
+// The protobuf format is detected by a length-delimited message starting with the name of the MetricFamily,
+// and the text format is assumed otherwise, in the same way as the Scraper falls back to the text format.
+func detectFormat(r *bufio.Reader) expfmt.Format {
+	b, _ := r.Peek(2*binary.MaxVarintLen64 + 2)
+
+	length, n := binary.Uvarint(b)
+	if n <= 0 || length == 0 || n >= len(b) {
+		return expfmt.FmtText
+	}
+
+	// The first field of a MetricFamily is the name, whose tag is 0x0a (field 1, wire type 2).
+	if b[n] != 0x0a {
+		return expfmt.FmtText
+	}
+
+	nameLength, m := binary.Uvarint(b[n+1:])
+	if m <= 0 || nameLength == 0 || nameLength >= length || n+1+m >= len(b) || !isMetricNameStart(b[n+1+m]) {
+		return expfmt.FmtText
+	}
+
+	return expfmt.FmtProtoDelim
+}
+
+// isMetricNameStart reports whether the byte can be the first character of a metric name.
+func isMetricNameStart(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c == ':'
+}
+
+// newTextfileGaugeMetricFamily creates a gauge MetricFamily without any metrics.
+func newTextfileGaugeMetricFamily(name, help string) *dto.MetricFamily {
+	return &dto.MetricFamily{
+		Name: &name,
+		Help: &help,
+		Type: dto.MetricType_GAUGE.Enum(),
+	}
+}
+
+// addTextfileGauge adds a gauge metric with the "file" label to the MetricFamily.
+func addTextfileGauge(mf *dto.MetricFamily, file string, value float64) {
+	mf.Metric = append(mf.Metric, &dto.Metric{
+		Label: labelSetToLabelPairs(model.LabelSet{"file": model.LabelValue(file)}),
+		Gauge: &dto.Gauge{Value: &value},
+	})
+}
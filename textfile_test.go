@@ -0,0 +1,157 @@
+package promaggr_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/d-kuro/promaggr"
+	"github.com/d-kuro/promaggr/internal"
+	"github.com/google/go-cmp/cmp"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+)
+
+func TestFileSource(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	mtime := time.Unix(1625097600, 0)
+
+	files := map[string]string{
+		"batch.prom": `# HELP batch_last_success_timestamp_seconds Dummy text.
+# TYPE batch_last_success_timestamp_seconds gauge
+batch_last_success_timestamp_seconds 1.6250976e+09
+`,
+		"backup.prom": `# HELP batch_last_success_timestamp_seconds Dummy text.
+# TYPE batch_last_success_timestamp_seconds gauge
+batch_last_success_timestamp_seconds 1.6250975e+09
+`,
+		"broken.prom": `broken{ 1
+`,
+		"ignored.txt": `ignored_metric 1
+`,
+	}
+
+	for name, content := range files {
+		file := filepath.Join(dir, name)
+		if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+
+		if err := os.Chtimes(file, mtime, mtime); err != nil {
+			t.Fatalf("failed to change the modification time of %s: %v", name, err)
+		}
+	}
+
+	source := promaggr.NewFileSource(dir,
+		promaggr.FileSourceLabels(model.LabelSet{"host": "foo"}),
+		promaggr.FileLabels("batch.prom", model.LabelSet{"job": "batch"}),
+		promaggr.FileLabels("backup.prom", model.LabelSet{"job": "backup"}),
+	)
+
+	mfs, err := source.Fetch(context.Background())
+	if err != nil {
+		t.Fatalf("failed to fetch: %v", err)
+	}
+
+	sort.Slice(mfs, func(i, j int) bool {
+		return mfs[i].GetName() < mfs[j].GetName()
+	})
+
+	out := bytes.Buffer{}
+
+	for _, mf := range mfs {
+		sort.Slice(mf.Metric, func(i, j int) bool {
+			return mf.Metric[i].String() < mf.Metric[j].String()
+		})
+
+		if _, err := expfmt.MetricFamilyToText(&out, mf); err != nil {
+			t.Fatalf("failed to convert MetricFamily to text: %v", err)
+		}
+	}
+
+	got := out.String()
+	want := `# HELP batch_last_success_timestamp_seconds Dummy text.
+# TYPE batch_last_success_timestamp_seconds gauge
+batch_last_success_timestamp_seconds{host="foo",job="backup"} 1.6250975e+09
+batch_last_success_timestamp_seconds{host="foo",job="batch"} 1.6250976e+09
+# HELP promaggr_textfile_mtime_seconds Unixtime mtime of textfiles successfully read.
+# TYPE promaggr_textfile_mtime_seconds gauge
+promaggr_textfile_mtime_seconds{file="` + filepath.Join(dir, "backup.prom") + `"} 1.6250976e+09
+promaggr_textfile_mtime_seconds{file="` + filepath.Join(dir, "batch.prom") + `"} 1.6250976e+09
+# HELP promaggr_textfile_scrape_error 1 if there was an error reading the textfile, 0 otherwise.
+# TYPE promaggr_textfile_scrape_error gauge
+promaggr_textfile_scrape_error{file="` + filepath.Join(dir, "backup.prom") + `"} 0
+promaggr_textfile_scrape_error{file="` + filepath.Join(dir, "batch.prom") + `"} 0
+promaggr_textfile_scrape_error{file="` + filepath.Join(dir, "broken.prom") + `"} 1
+`
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("file source metrics mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestFileSourceFormats(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	buf := bytes.Buffer{}
+	encoder := expfmt.NewEncoder(&buf, expfmt.FmtProtoDelim)
+
+	if err := encoder.Encode(internal.NewCounterMetricFamilyFixture("proto_total")); err != nil {
+		t.Fatalf("failed to encode MetricFamily: %v", err)
+	}
+
+	files := map[string][]byte{
+		"proto.prom": buf.Bytes(),
+		"text.prom":  []byte("# TYPE text_total counter\ntext_total 1\n"),
+		// The type conflicts with text.prom, so the metric is dropped instead of merged.
+		"zz_conflict.prom": []byte("# TYPE text_total gauge\ntext_total 2\n"),
+	}
+
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), content, 0o600); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+
+	mfs, err := promaggr.NewFileSource(dir).Fetch(context.Background())
+	if err != nil {
+		t.Fatalf("failed to fetch: %v", err)
+	}
+
+	values := make(map[string]float64)
+
+	for _, mf := range mfs {
+		for _, m := range mf.GetMetric() {
+			if mf.GetType() == dto.MetricType_COUNTER {
+				values[mf.GetName()] = m.GetCounter().GetValue()
+			}
+		}
+
+		if mf.GetName() == "text_total" && mf.GetType() != dto.MetricType_COUNTER {
+			t.Errorf("type of text_total mismatch: want(counter) got(%s)", mf.GetType())
+		}
+	}
+
+	if diff := cmp.Diff(map[string]float64{"proto_total": 123456, "text_total": 1}, values); diff != "" {
+		t.Errorf("values mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestFileSourceNotExist(t *testing.T) {
+	t.Parallel()
+
+	source := promaggr.NewFileSource(filepath.Join(t.TempDir(), "not-exist"))
+
+	if _, err := source.Fetch(context.Background()); err == nil {
+		t.Errorf("fetch from a directory that does not exist succeeded")
+	}
+}
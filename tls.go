@@ -0,0 +1,162 @@
+package promaggr
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrInvalidCA is returned when no certificate could be parsed from the CA file.
+	ErrInvalidCA = errors.New("no valid certificate found in the CA file")
+
+	// ErrIncompleteClientCertificate is returned when only one of the client certificate and key files is configured.
+	ErrIncompleteClientCertificate = errors.New("both the client certificate and key files must be configured")
+)
+
+// TLSConfig configures the TLS connection to the scraping target.
+// The fields are the same as the tls_config of Prometheus.
+// The files are loaded again when they change, so that rotated certificates are used without a restart.
+type TLSConfig struct {
+	// CAFile is the CA certificate file to validate the server certificate with.
+	// If not specified, the system certificate pool will be used.
+	CAFile string
+
+	// CertFile is the client certificate file for the client certificate authentication.
+	CertFile string
+
+	// KeyFile is the client key file for the client certificate authentication.
+	KeyFile string
+
+	// ServerName is the server name used to verify the server certificate.
+	// If not specified, the host name of the URL will be used.
+	ServerName string
+
+	// InsecureSkipVerify disables the validation of the server certificate.
+	InsecureSkipVerify bool
+}
+
+// TLS is an option available for NewScraper.
+// The TLS connection to the scraping target will be configured by the given TLSConfig.
+func TLS(config TLSConfig) ScraperOption {
+	return func(s *Scraper) {
+		s.TLSConfig = &config
+	}
+}
+
+// build loads the files and builds a tls.Config.
+func (c *TLSConfig) build() (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         c.ServerName,
+		InsecureSkipVerify: c.InsecureSkipVerify, //nolint:gosec // It is explicitly configured by the user.
+	}
+
+	if c.CAFile != "" {
+		b, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidCA, c.CAFile)
+		}
+
+		config.RootCAs = pool
+	}
+
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return nil, ErrIncompleteClientCertificate
+	}
+
+	if c.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
+
+// modTimes returns the modification times of the files.
+// A file that does not exist has the zero time.
+func (c *TLSConfig) modTimes() [3]time.Time {
+	var modTimes [3]time.Time
+
+	for i, file := range [...]string{c.CAFile, c.CertFile, c.KeyFile} {
+		if file == "" {
+			continue
+		}
+
+		if info, err := os.Stat(file); err == nil {
+			modTimes[i] = info.ModTime()
+		}
+	}
+
+	return modTimes
+}
+
+// tlsRoundTripper is a http.RoundTripper that rebuilds the transport when the files of the TLSConfig change.
+type tlsRoundTripper struct {
+	config TLSConfig
+
+	// newTransport returns a new transport using the given tls.Config.
+	newTransport func(*tls.Config) *http.Transport
+
+	mutex     sync.Mutex
+	modTimes  [3]time.Time
+	transport *http.Transport
+}
+
+// newTLSRoundTripper creates and returns a new tlsRoundTripper.
+func newTLSRoundTripper(config TLSConfig, newTransport func(*tls.Config) *http.Transport) *tlsRoundTripper {
+	return &tlsRoundTripper{
+		config:       config,
+		newTransport: newTransport,
+	}
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (rt *tlsRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	transport, err := rt.currentTransport()
+	if err != nil {
+		return nil, err
+	}
+
+	return transport.RoundTrip(req)
+}
+
+// currentTransport returns the transport, rebuilding it if any of the files has changed since it was built.
+func (rt *tlsRoundTripper) currentTransport() (*http.Transport, error) {
+	modTimes := rt.config.modTimes()
+
+	rt.mutex.Lock()
+	defer rt.mutex.Unlock()
+
+	if rt.transport != nil && modTimes == rt.modTimes {
+		return rt.transport, nil
+	}
+
+	config, err := rt.config.build()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS config: %w", err)
+	}
+
+	if rt.transport != nil {
+		rt.transport.CloseIdleConnections()
+	}
+
+	rt.transport = rt.newTransport(config)
+	rt.modTimes = modTimes
+
+	return rt.transport, nil
+}
@@ -0,0 +1,183 @@
+package promaggr_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/d-kuro/promaggr"
+	"github.com/prometheus/common/expfmt"
+)
+
+func TestScraperTLS(t *testing.T) {
+	t.Parallel()
+
+	serverCA := newTestCertificate(t, nil, "server-ca")
+	serverCert := newTestCertificate(t, serverCA, "server")
+	clientCA := newTestCertificate(t, nil, "client-ca")
+	clientCert := newTestCertificate(t, clientCA, "client")
+	otherCA := newTestCertificate(t, nil, "other-ca")
+	otherClientCert := newTestCertificate(t, otherCA, "client")
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCA.cert)
+
+	scrapeTarget := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", string(expfmt.FmtText))
+		_, _ = io.WriteString(w, metricsText)
+	}))
+	scrapeTarget.TLS = &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{serverCert.tlsCertificate(t)},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	}
+	scrapeTarget.StartTLS()
+	defer scrapeTarget.Close()
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+
+	// The client certificate is not trusted by the server at first.
+	otherCA.writeCert(t, caFile)
+	otherClientCert.writeCert(t, certFile)
+	otherClientCert.writeKey(t, keyFile)
+
+	scraper := promaggr.NewScraper(scrapeTarget.URL, promaggr.TLS(promaggr.TLSConfig{
+		CAFile:     caFile,
+		CertFile:   certFile,
+		KeyFile:    keyFile,
+		ServerName: "server",
+	}))
+
+	if _, err := scraper.Scrape(context.Background()); err == nil {
+		t.Fatalf("scrape succeeded with an untrusted certificate")
+	}
+
+	// The rotated certificates must be loaded without creating a new Scraper.
+	serverCA.writeCert(t, caFile)
+	clientCert.writeCert(t, certFile)
+	clientCert.writeKey(t, keyFile)
+
+	modTime := time.Now().Add(time.Minute)
+	for _, file := range []string{caFile, certFile, keyFile} {
+		if err := os.Chtimes(file, modTime, modTime); err != nil {
+			t.Fatalf("failed to change the modification time of %s: %v", file, err)
+		}
+	}
+
+	if _, err := scraper.Scrape(context.Background()); err != nil {
+		t.Fatalf("failed to scrape after the certificate rotation: %v", err)
+	}
+}
+
+type testCertificate struct {
+	cert *x509.Certificate
+	der  []byte
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCertificate(t *testing.T, parent *testCertificate, commonName string) *testCertificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatalf("failed to generate serial number: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{commonName},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	signerCert, signerKey := template, key
+
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+	} else {
+		signerCert, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signerCert, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %v", err)
+	}
+
+	return &testCertificate{cert: cert, der: der, key: key}
+}
+
+func (c *testCertificate) certPEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.der})
+}
+
+func (c *testCertificate) keyPEM(t *testing.T) []byte {
+	t.Helper()
+
+	der, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+}
+
+func (c *testCertificate) tlsCertificate(t *testing.T) tls.Certificate {
+	t.Helper()
+
+	cert, err := tls.X509KeyPair(c.certPEM(), c.keyPEM(t))
+	if err != nil {
+		t.Fatalf("failed to create key pair: %v", err)
+	}
+
+	return cert
+}
+
+func (c *testCertificate) writeCert(t *testing.T, file string) {
+	t.Helper()
+
+	if err := os.WriteFile(file, c.certPEM(), 0o600); err != nil {
+		t.Fatalf("failed to write certificate: %v", err)
+	}
+}
+
+func (c *testCertificate) writeKey(t *testing.T, file string) {
+	t.Helper()
+
+	if err := os.WriteFile(file, c.keyPEM(t), 0o600); err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+}
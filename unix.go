@@ -0,0 +1,69 @@
+package promaggr
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+)
+
+// ErrUnixURLWithHTTPClient is returned when a unix URL is scraped with the HTTPClient,
+// which cannot be made to dial the Unix domain socket.
+var ErrUnixURLWithHTTPClient = errors.New("unix URL cannot be used with HTTPClient")
+
+// unixScheme is the URL scheme for the scraping targets listening on Unix domain sockets.
+const unixScheme = "unix://"
+
+// UnixSocket is an option available for NewScraper.
+// The request will be sent over the Unix domain socket at the given path,
+// and the host of the URL will be ignored.
+// Alternatively, the URL can be specified in the form of "unix:///path/to.sock:/metrics".
+func UnixSocket(path string) ScraperOption {
+	return func(s *Scraper) {
+		s.UnixSocket = path
+	}
+}
+
+// parseUnixURL splits a URL in the form of "unix:///path/to.sock:/metrics"
+// into the socket path and the HTTP URL to be requested over the socket.
+// If the URL is not a unix URL, ok will be false.
+func parseUnixURL(rawURL string) (socket, httpURL string, ok bool) {
+	if !strings.HasPrefix(rawURL, unixScheme) {
+		return "", "", false
+	}
+
+	socket = strings.TrimPrefix(rawURL, unixScheme)
+	path := "/"
+
+	if i := strings.Index(socket, ":"); i >= 0 {
+		socket, path = socket[:i], socket[i+1:]
+	}
+
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	return socket, "http://localhost" + path, true
+}
+
+// unixSocket returns the path of the Unix domain socket to send the request over.
+// If the request is not sent over a Unix domain socket, an empty string is returned.
+func (s *Scraper) unixSocket() string {
+	if s.UnixSocket != "" {
+		return s.UnixSocket
+	}
+
+	socket, _, _ := parseUnixURL(s.URL)
+
+	return socket
+}
+
+// dialUnix returns a function to dial the Unix domain socket at the given path,
+// regardless of the network and the address.
+func dialUnix(socket string) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	var dialer net.Dialer
+
+	return func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return dialer.DialContext(ctx, "unix", socket)
+	}
+}
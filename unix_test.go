@@ -0,0 +1,83 @@
+package promaggr_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/d-kuro/promaggr"
+	"github.com/prometheus/common/expfmt"
+)
+
+func TestScraperUnixSocket(t *testing.T) {
+	t.Parallel()
+
+	socket := filepath.Join(t.TempDir(), "exporter.sock")
+
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("failed to listen on the unix socket: %v", err)
+	}
+
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/metrics" {
+			t.Errorf("path mismatch: want(%s) got(%s)", "/metrics", r.URL.Path)
+		}
+
+		w.Header().Set("Content-Type", string(expfmt.FmtText))
+		_, _ = io.WriteString(w, metricsText)
+	})}
+
+	go func() {
+		_ = server.Serve(listener)
+	}()
+
+	t.Cleanup(func() {
+		_ = server.Close()
+	})
+
+	tests := []struct {
+		name    string
+		scraper *promaggr.Scraper
+	}{
+		{
+			name:    "unix URL",
+			scraper: promaggr.NewScraper("unix://" + socket + ":/metrics"),
+		},
+		{
+			name:    "UnixSocket option",
+			scraper: promaggr.NewScraper("http://localhost/metrics", promaggr.UnixSocket(socket)),
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mfs, err := tt.scraper.Scrape(context.Background())
+			if err != nil {
+				t.Fatalf("failed to scrape: %v", err)
+			}
+
+			if len(mfs) != 4 {
+				t.Errorf("mismatch in the number of metric families: want(%d) got(%d)", 4, len(mfs))
+			}
+		})
+	}
+}
+
+func TestScraperUnixURLWithHTTPClient(t *testing.T) {
+	t.Parallel()
+
+	scraper := promaggr.NewScraper("unix:///run/exporter.sock:/metrics", promaggr.HTTPClient(&http.Client{}))
+
+	_, err := scraper.Scrape(context.Background())
+	if !errors.Is(err, promaggr.ErrUnixURLWithHTTPClient) {
+		t.Errorf("error mismatch: want(%v) got(%v)", promaggr.ErrUnixURLWithHTTPClient, err)
+	}
+}